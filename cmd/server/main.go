@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"eino-gerrit-review/internal/app/eino/core"
+	"eino-gerrit-review/internal/app/scheduler"
 	"eino-gerrit-review/internal/config"
 	"eino-gerrit-review/internal/web"
 	"os"
@@ -21,12 +23,61 @@ func main() {
 		}
 	}
 	s.SetPort(port)
-	s.Group("/").ALL("/health", func(r *ghttp.Request) { r.Response.WriteJson(g.Map{"code": 0, "msg": "ok"}) })
-	web.RegisterRoutes(s)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	cfg := config.Load()
+	pool := scheduler.NewWorkerPool(cfg.WorkerNum, cfg.QueueSize)
+	classAttempts, err := scheduler.ParseClassAttempts(cfg.RetryClassAttempts)
+	if err != nil {
+		g.Log().Fatalf(ctx, "RETRY_CLASS_ATTEMPTS: %v", err)
+	}
+	pool.SetRetryPolicy(scheduler.RetryPolicy{
+		MaxAttempts:   cfg.RetryMaxAttempts,
+		BaseDelay:     time.Duration(cfg.RetryBaseDelayMs) * time.Millisecond,
+		MaxDelay:      time.Duration(cfg.RetryMaxDelayMs) * time.Millisecond,
+		ClassAttempts: classAttempts,
+	})
+	pool.Run(ctx)
+
+	s.Group("/").ALL("/health", func(r *ghttp.Request) { r.Response.WriteJson(g.Map{"code": 0, "msg": "ok"}) })
+	web.RegisterRoutes(s, pool)
+
+	ttl := time.Duration(cfg.ReviewTTLHours) * time.Hour
+	if cfg.ReviewStorePath != "" {
+		st, err := core.OpenFileReviewStore(cfg.ReviewStorePath, ttl)
+		if err != nil {
+			g.Log().Fatalf(ctx, "open review store %s: %v", cfg.ReviewStorePath, err)
+		}
+		core.SetReviewStore(st)
+		g.Log().Infof(ctx, "Using file review store: %s", cfg.ReviewStorePath)
+	} else {
+		core.SetReviewStore(core.NewMemoryReviewStore(ttl))
+	}
+
+	go func() {
+		t := time.NewTicker(10 * time.Minute)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-t.C:
+				if n := core.Reviews().Evict(now); n > 0 {
+					g.Log().Infof(ctx, "Evicted %d expired reviews", n)
+				}
+			}
+		}
+	}()
+
+	if cfg.WatchConfigPath != "" {
+		watches := scheduler.NewWatchManager(cfg.WatchConfigPath, pool)
+		web.SetWatchManager(watches)
+		g.Log().Infof(ctx, "Starting watches from: %s", cfg.WatchConfigPath)
+		go watches.Run(ctx, 30*time.Second)
+	}
+
 	go func() {
 		path := os.Getenv("RULE_CONFIG_PATH")
 		if path == "" {
@@ -45,5 +96,13 @@ func main() {
 		}
 	}()
 
+	// s.Run returns once the HTTP server has shut down on SIGTERM/SIGINT;
+	// let queued and in-flight reviews finish before exiting.
 	s.Run()
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), time.Duration(cfg.DrainTimeoutSec)*time.Second)
+	defer drainCancel()
+	g.Log().Infof(drainCtx, "Draining worker pool (%d queued)", pool.Pending())
+	if err := pool.Shutdown(drainCtx); err != nil {
+		g.Log().Warningf(drainCtx, "Worker pool drain aborted: %v", err)
+	}
 }
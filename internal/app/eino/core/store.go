@@ -1,23 +1,226 @@
 package core
 
-import "sync"
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
 
+// ReviewStored is a generated review preview together with its bookkeeping data.
 type ReviewStored struct {
-	Payload   map[string]interface{}
+	ID           string                 `json:"id"`
+	Payload      map[string]interface{} `json:"payload"`
+	ChangeNum    string                 `json:"changeNum"`
+	Patchset     string                 `json:"patchset"`
+	Model        string                 `json:"model,omitempty"`
+	Published    bool                   `json:"published"`
+	PublishError string                 `json:"publishError,omitempty"`
+	CreatedAt    time.Time              `json:"createdAt"`
+	UpdatedAt    time.Time              `json:"updatedAt"`
+	PublishedAt  time.Time              `json:"publishedAt"`
+}
+
+// ReviewFilter narrows the result of ReviewStore.List. Empty fields match everything.
+type ReviewFilter struct {
 	ChangeNum string
 	Patchset  string
+	Limit     int
+}
+
+// ReviewStore persists review previews between generation and publishing.
+type ReviewStore interface {
+	Put(r ReviewStored) error
+	Get(id string) (ReviewStored, bool)
+	List(f ReviewFilter) []ReviewStored
+	MarkPublished(id string, publishErr error) error
+	// Evict drops every review older than the store TTL and returns how many were removed.
+	Evict(now time.Time) int
 }
 
-var reviews sync.Map
+var ErrReviewNotFound = errors.New("review not found")
 
-func PutReview(id string, payload map[string]interface{}, changeNum, patchset string) {
-	reviews.Store(id, ReviewStored{Payload: payload, ChangeNum: changeNum, Patchset: patchset})
+// reviewStore keeps all reviews in memory and, when path is set, mirrors them
+// into a single JSON file that is rewritten atomically on every mutation.
+type reviewStore struct {
+	mu      sync.RWMutex
+	reviews map[string]ReviewStored
+	ttl     time.Duration
+	path    string
 }
 
-func GetReview(id string) (ReviewStored, bool) {
-	v, ok := reviews.Load(id)
-	if !ok {
+// NewMemoryReviewStore returns a process-local store. ttl <= 0 disables eviction.
+func NewMemoryReviewStore(ttl time.Duration) ReviewStore {
+	return &reviewStore{reviews: make(map[string]ReviewStored), ttl: ttl}
+}
+
+// OpenFileReviewStore returns a store backed by the JSON file at path,
+// loading any reviews persisted by a previous run.
+func OpenFileReviewStore(path string, ttl time.Duration) (ReviewStore, error) {
+	s := &reviewStore{reviews: make(map[string]ReviewStored), ttl: ttl, path: path}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(b) > 0 {
+		var arr []ReviewStored
+		if err := json.Unmarshal(b, &arr); err != nil {
+			return nil, err
+		}
+		for _, r := range arr {
+			s.reviews[r.ID] = r
+		}
+	}
+	s.Evict(time.Now())
+	return s, nil
+}
+
+func (s *reviewStore) Put(r ReviewStored) error {
+	if r.ID == "" {
+		return errors.New("review id is empty")
+	}
+	now := time.Now()
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = now
+	}
+	r.UpdatedAt = now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reviews[r.ID] = r
+	return s.persistLocked()
+}
+
+func (s *reviewStore) Get(id string) (ReviewStored, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.reviews[id]
+	if !ok || s.expired(r, time.Now()) {
 		return ReviewStored{}, false
 	}
-	return v.(ReviewStored), true
+	return r, true
+}
+
+func (s *reviewStore) List(f ReviewFilter) []ReviewStored {
+	now := time.Now()
+	s.mu.RLock()
+	out := make([]ReviewStored, 0, len(s.reviews))
+	for _, r := range s.reviews {
+		if s.expired(r, now) {
+			continue
+		}
+		if f.ChangeNum != "" && r.ChangeNum != f.ChangeNum {
+			continue
+		}
+		if f.Patchset != "" && r.Patchset != f.Patchset {
+			continue
+		}
+		out = append(out, r)
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out
+}
+
+func (s *reviewStore) MarkPublished(id string, publishErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reviews[id]
+	if !ok {
+		return ErrReviewNotFound
+	}
+	now := time.Now()
+	r.UpdatedAt = now
+	if publishErr != nil {
+		r.PublishError = publishErr.Error()
+	} else {
+		r.Published = true
+		r.PublishError = ""
+		r.PublishedAt = now
+	}
+	s.reviews[id] = r
+	return s.persistLocked()
+}
+
+func (s *reviewStore) Evict(now time.Time) int {
+	if s.ttl <= 0 {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, r := range s.reviews {
+		if s.expired(r, now) {
+			delete(s.reviews, id)
+			n++
+		}
+	}
+	if n > 0 {
+		_ = s.persistLocked()
+	}
+	return n
+}
+
+func (s *reviewStore) expired(r ReviewStored, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(r.CreatedAt) > s.ttl
+}
+
+// persistLocked writes the whole store to a temp file and renames it over
+// the target so a crash never leaves a half-written file behind.
+func (s *reviewStore) persistLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	arr := make([]ReviewStored, 0, len(s.reviews))
+	for _, r := range s.reviews {
+		arr = append(arr, r)
+	}
+	b, err := json.Marshal(arr)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+var (
+	defaultStore   ReviewStore = NewMemoryReviewStore(0)
+	defaultStoreMu sync.RWMutex
+)
+
+// SetReviewStore replaces the store used by the package-level helpers.
+func SetReviewStore(s ReviewStore) {
+	defaultStoreMu.Lock()
+	defaultStore = s
+	defaultStoreMu.Unlock()
+}
+
+// Reviews returns the store used by the package-level helpers.
+func Reviews() ReviewStore {
+	defaultStoreMu.RLock()
+	defer defaultStoreMu.RUnlock()
+	return defaultStore
+}
+
+func PutReview(r ReviewStored) error { return Reviews().Put(r) }
+
+func GetReview(id string) (ReviewStored, bool) { return Reviews().Get(id) }
+
+func ListReviews(f ReviewFilter) []ReviewStored { return Reviews().List(f) }
+
+func MarkReviewPublished(id string, publishErr error) error {
+	return Reviews().MarkPublished(id, publishErr)
 }
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileReviewStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reviews.json")
+	s, err := OpenFileReviewStore(path, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := s.Put(ReviewStored{ID: "R1", ChangeNum: "42", Patchset: "3", Model: "m", Payload: map[string]interface{}{"message": "ok"}}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := s.MarkPublished("R1", nil); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if err := s.MarkPublished("missing", nil); !errors.Is(err, ErrReviewNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	reopened, err := OpenFileReviewStore(path, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	v, ok := reopened.Get("R1")
+	if !ok {
+		t.Fatalf("review lost after reopen")
+	}
+	if v.ChangeNum != "42" || v.Patchset != "3" || v.Model != "m" || !v.Published || v.Payload["message"] != "ok" {
+		t.Fatalf("unexpected review: %+v", v)
+	}
+}
+
+func TestReviewStoreTTLAndList(t *testing.T) {
+	s := NewMemoryReviewStore(time.Hour)
+	old := time.Now().Add(-2 * time.Hour)
+	_ = s.Put(ReviewStored{ID: "old", ChangeNum: "1", CreatedAt: old})
+	_ = s.Put(ReviewStored{ID: "a", ChangeNum: "1", Patchset: "2"})
+	_ = s.Put(ReviewStored{ID: "b", ChangeNum: "2", Patchset: "1"})
+
+	if _, ok := s.Get("old"); ok {
+		t.Fatalf("expired review should not be returned")
+	}
+	if got := s.List(ReviewFilter{ChangeNum: "1"}); len(got) != 1 || got[0].ID != "a" {
+		t.Fatalf("unexpected list: %+v", got)
+	}
+	if n := s.Evict(time.Now()); n != 1 {
+		t.Fatalf("expected 1 evicted, got %d", n)
+	}
+	if got := s.List(ReviewFilter{Limit: 1}); len(got) != 1 {
+		t.Fatalf("limit not applied: %d", len(got))
+	}
+}
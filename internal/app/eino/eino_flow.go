@@ -3,6 +3,7 @@ package eino
 import (
 	"context"
 	"eino-gerrit-review/internal/app/tools"
+	"eino-gerrit-review/internal/config"
 	"fmt"
 	"os"
 
@@ -16,6 +17,8 @@ import (
 
 // BuildReviewGraph constructs an Eino Graph that orchestrates the review pipeline.
 // Input: map[string]any{"changeNum":string, "patchset":string, "enableContext":bool}
+// plus optional "project" and "branch"; without them the change is looked up to
+// pick the rule profile and the git mirror.
 // Output: map[string]any{"preview": map[string]any}
 func BuildReviewGraph() (*compose.Graph[map[string]any, map[string]any], error) {
 	g := compose.NewGraph[map[string]any, map[string]any]()
@@ -62,6 +65,11 @@ type DiffOutput struct {
 	Diffs     []map[string]interface{}
 	ChangeNum string
 	Patchset  string
+	Project   string
+	Branch    string
+	Profile   config.RuleProfile
+	// Notices are posted as they are, e.g. a broken in-repo config.
+	Notices []tools.RuleAdvice
 }
 
 func diffNode(ctx context.Context, in map[string]any) (*DiffOutput, error) {
@@ -77,56 +85,76 @@ func diffNode(ctx context.Context, in map[string]any) (*DiffOutput, error) {
 	fmt.Printf("DEBUG: Got %d raw diffs\n", len(diffs))
 	out := (&tools.DiffTool{}).Parse(diffs)
 	fmt.Printf("DEBUG: Parsed %d diffs\n", len(out))
-	return &DiffOutput{Diffs: out, ChangeNum: changeNum, Patchset: patchset}, nil
+	project, _ := in["project"].(string)
+	branch, _ := in["branch"].(string)
+	project, branch = gt.ChangeTarget(changeNum, project, branch)
+	prof, notices, err := gt.ReviewProfile(changeNum, patchset, project, branch)
+	if err != nil {
+		fmt.Printf("DEBUG: ReviewProfile error: %v\n", err)
+		return nil, err
+	}
+	out = tools.DropIgnored(out, prof)
+	fmt.Printf("DEBUG: Using rule profile %q (repo config %q), %d diffs after ignores\n", prof.Name, prof.RepoConfig, len(out))
+	return &DiffOutput{Diffs: out, ChangeNum: changeNum, Patchset: patchset, Project: project, Branch: branch, Profile: prof, Notices: notices}, nil
 }
 
-func contextNode(ctx context.Context, in *DiffOutput) (struct {
-	Diffs []map[string]interface{}
-	Ctxs  []tools.ContextInfo
-}, error) {
+type ContextOutput struct {
+	Diffs   []map[string]interface{}
+	Ctxs    []tools.ContextInfo
+	Profile config.RuleProfile
+	Notices []tools.RuleAdvice
+}
+
+func contextNode(ctx context.Context, in *DiffOutput) (ContextOutput, error) {
 	enable, _ := ctx.Value("enableContext").(bool)
 	fmt.Printf("DEBUG: Fetching context (enable=%v)\n", enable)
-	ctxs := (&tools.CodeContextTool{}).Fetch(enable, in.ChangeNum, in.Patchset, in.Diffs)
+	ctxs := (&tools.CodeContextTool{Project: in.Project}).Fetch(enable, in.ChangeNum, in.Patchset, in.Diffs)
 	fmt.Printf("DEBUG: Fetched %d context items\n", len(ctxs))
-	return struct {
-		Diffs []map[string]interface{}
-		Ctxs  []tools.ContextInfo
-	}{Diffs: in.Diffs, Ctxs: ctxs}, nil
+	return ContextOutput{Diffs: in.Diffs, Ctxs: ctxs, Profile: in.Profile, Notices: in.Notices}, nil
 }
 
-func analyzeNode(ctx context.Context, in struct {
-	Diffs []map[string]interface{}
-	Ctxs  []tools.ContextInfo
-}) (struct {
+type AnalyzeOutput struct {
 	Static []tools.RuleAdvice
 	Llm    []tools.LLMAdvice
-}, error) {
+	// Suppressed counts findings silenced by ai-review:ignore markers.
+	Suppressed int
+}
+
+func analyzeNode(ctx context.Context, in ContextOutput) (AnalyzeOutput, error) {
 	fmt.Println("DEBUG: Starting analysis...")
-	static := (&tools.StaticRuleTool{}).Run(in.Diffs, in.Ctxs)
+	st := &tools.StaticRuleTool{Profile: &in.Profile}
+	static := st.Run(in.Diffs, in.Ctxs)
 	fmt.Printf("DEBUG: Static analysis found %d issues\n", len(static))
-	prompt := tools.BuildPrompt(joinPatches(in.Diffs), in.Ctxs)
+	prompt := tools.WithGuidelines(tools.BuildPrompt(joinPatches(in.Diffs), in.Ctxs), in.Profile.PromptExtra)
 	fmt.Printf("DEBUG: Generated prompt size: %d bytes\n", len(prompt))
 	llm, err := (&tools.LLMTool{}).Generate(prompt)
 	if err != nil {
 		fmt.Printf("DEBUG: LLM error: %v\n", err)
+		// Transient model failures fail the run so the scheduler can retry it;
+		// anything else degrades to a static-only review.
+		if tools.ErrorClass(err) != "" {
+			return AnalyzeOutput{}, err
+		}
 	}
 	fmt.Printf("DEBUG: LLM found %d issues\n", len(llm))
-	return struct {
-		Static []tools.RuleAdvice
-		Llm    []tools.LLMAdvice
-	}{Static: static, Llm: llm}, nil
+	llm, n := tools.FilterSuppressedLLM(llm, in.Diffs, in.Ctxs)
+	fmt.Printf("DEBUG: Suppressed %d static and %d LLM findings\n", st.Suppressed, n)
+	static, llm = tools.FilterSeverity(static, llm, in.Profile)
+	return AnalyzeOutput{Static: append(in.Notices, static...), Llm: llm, Suppressed: st.Suppressed + n}, nil
 }
 
-func mergeNode(ctx context.Context, in struct {
-	Static []tools.RuleAdvice
-	Llm    []tools.LLMAdvice
-}) ([]map[string]interface{}, error) {
+type MergeOutput struct {
+	Advice     []map[string]interface{}
+	Suppressed int
+}
+
+func mergeNode(ctx context.Context, in AnalyzeOutput) (MergeOutput, error) {
 	m := tools.Synthesize(in.Static, in.Llm)
-	return m, nil
+	return MergeOutput{Advice: m, Suppressed: in.Suppressed}, nil
 }
 
-func formatNode(ctx context.Context, advs []map[string]interface{}) (map[string]interface{}, error) {
-	return map[string]interface{}{"preview": tools.FormatForGerrit(advs)}, nil
+func formatNode(ctx context.Context, in MergeOutput) (map[string]interface{}, error) {
+	return map[string]interface{}{"preview": tools.FormatReview(in.Advice, in.Suppressed)}, nil
 }
 
 // joinPatches helper used in LLM prompt building
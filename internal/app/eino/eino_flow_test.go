@@ -2,9 +2,14 @@ package eino
 
 import (
 	"context"
+	"eino-gerrit-review/internal/app/tools"
+	"eino-gerrit-review/internal/testutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/cloudwego/eino/callbacks"
 	"github.com/cloudwego/eino/compose"
 )
 
@@ -31,6 +36,152 @@ func TestBuildReviewGraph(t *testing.T) {
 	}
 }
 
+// invokeNode runs the review graph on in and returns what node was given
+// and what it returned.
+func invokeNode(t *testing.T, ctx context.Context, in map[string]any, node string) (callbacks.CallbackInput, callbacks.CallbackOutput) {
+	t.Helper()
+	g, err := BuildReviewGraph()
+	if err != nil {
+		t.Fatalf("build err: %v", err)
+	}
+	r, err := g.Compile(ctx, compose.WithMaxRunSteps(20))
+	if err != nil {
+		t.Fatalf("compile err: %v", err)
+	}
+	var input callbacks.CallbackInput
+	var output callbacks.CallbackOutput
+	h := callbacks.NewHandlerBuilder().
+		OnStartFn(func(ctx context.Context, _ *callbacks.RunInfo, in callbacks.CallbackInput) context.Context {
+			input = in
+			return ctx
+		}).
+		OnEndFn(func(ctx context.Context, _ *callbacks.RunInfo, out callbacks.CallbackOutput) context.Context {
+			output = out
+			return ctx
+		}).Build()
+	if _, err := r.Invoke(ctx, in, compose.WithCallbacks(h).DesignateNode(node)); err != nil {
+		t.Fatalf("invoke err: %v", err)
+	}
+	if input == nil {
+		t.Fatalf("node %s not reached", node)
+	}
+	return input, output
+}
+
+// invokeCapturingContext runs the review graph on in and returns what the
+// context node was given.
+func invokeCapturingContext(t *testing.T, ctx context.Context, in map[string]any) *DiffOutput {
+	t.Helper()
+	got, _ := invokeNode(t, ctx, in, "context")
+	return got.(*DiffOutput)
+}
+
+func TestReviewGraphResolvesProject(t *testing.T) {
+	t.Setenv("GERRIT_BASE_URL", "")
+	// The mock Gerrit knows change 456 as android/develop.
+	got := invokeCapturingContext(t, context.Background(), map[string]any{"changeNum": "456", "patchset": "1"})
+	if got.Project != "android" || got.Branch != "develop" {
+		t.Fatalf("context node got project %q branch %q", got.Project, got.Branch)
+	}
+	got = invokeCapturingContext(t, context.Background(), map[string]any{"changeNum": "456", "patchset": "1", "project": "given", "branch": "b"})
+	if got.Project != "given" || got.Branch != "b" {
+		t.Fatalf("explicit project overridden: %q %q", got.Project, got.Branch)
+	}
+}
+
+func TestReviewGraphSearchesProjectMirror(t *testing.T) {
+	t.Setenv("GERRIT_BASE_URL", "")
+	t.Setenv("CONTEXT_GRANULARITY", "function")
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{
+		"android/app/Launcher.java": launcherJava,
+		"linux/app/Other.java":      "class Other {\n  void run() {\n    new MainActivity().onCreate();\n  }\n}\n",
+	})
+	t.Setenv("CONTEXT_MIRROR_DIR", root)
+
+	ctx := context.WithValue(context.Background(), "enableContext", true)
+	_, out := invokeNode(t, ctx, map[string]any{"changeNum": "456", "patchset": "1"}, "context")
+	var callers []string
+	for _, c := range out.(ContextOutput).Ctxs {
+		if c.ContextType == "callers" {
+			callers = append(callers, c.Source+":"+c.FilePath+":"+c.Symbol)
+		}
+	}
+	if strings.Join(callers, ",") != "mirror:app/Launcher.java:onCreate" {
+		t.Fatalf("callers %v", callers)
+	}
+}
+
+func TestReviewGraphCachesByProject(t *testing.T) {
+	t.Setenv("GERRIT_BASE_URL", "")
+	tools.FlushContextCache("")
+	ctx := context.WithValue(context.Background(), "enableContext", true)
+	invokeNode(t, ctx, map[string]any{"changeNum": "456", "patchset": "1"}, "context")
+	if n := tools.FlushContextCache("linux"); n != 0 {
+		t.Fatalf("flushed %d files of another project", n)
+	}
+	// Both mock files of change 456 were cached under its project.
+	if n := tools.FlushContextCache("android"); n != 2 {
+		t.Fatalf("flushed %d files", n)
+	}
+}
+
+const launcherJava = "class Launcher {\n  void start() {\n    new MainActivity().onCreate();\n  }\n}\n"
+
+// androidMirror builds a bare repository under base/android holding change 456
+// patch set 1, which rewrites the mock MainActivity.onCreate.
+func androidMirror(t *testing.T, base string) {
+	t.Helper()
+	const activity = "app/src/main/java/com/example/MainActivity.java"
+	testutil.GitRemote(t, filepath.Join(base, "android"), "refs/changes/56/456/1",
+		testutil.Commit{Message: "base", Files: map[string]string{
+			activity:            "class MainActivity { void onCreate() {\n} }\n",
+			"app/Launcher.java": launcherJava,
+		}},
+		testutil.Commit{Message: "change 456", Files: map[string]string{
+			activity: "class MainActivity { void onCreate() {\n  mirrored();\n} }\n",
+		}})
+}
+
+func TestReviewGraphReadsFromGitMirror(t *testing.T) {
+	t.Setenv("GERRIT_BASE_URL", "")
+	base := t.TempDir()
+	androidMirror(t, base)
+	t.Setenv("GIT_MIRROR_DIR", t.TempDir())
+	t.Setenv("GIT_MIRROR_PROJECTS", "android")
+	t.Setenv("GIT_MIRROR_URL", base)
+	t.Setenv("CONTEXT_GRANULARITY", "compare")
+
+	ctx := context.WithValue(context.Background(), "enableContext", true)
+	_, out := invokeNode(t, ctx, map[string]any{"changeNum": "456", "patchset": "1"}, "context")
+	var compare, callers []tools.ContextInfo
+	for _, c := range out.(ContextOutput).Ctxs {
+		switch c.ContextType {
+		case "compare":
+			compare = append(compare, c)
+		case "callers":
+			callers = append(callers, c)
+		}
+	}
+	if len(compare) != 1 {
+		t.Fatalf("compare context %+v", compare)
+	}
+	if c := compare[0]; c.Source != "mirror" || c.Symbol != "MainActivity.onCreate" || !strings.Contains(c.Content, "mirrored") || strings.Contains(c.OldContent, "mirrored") || c.OldContent == "" {
+		t.Fatalf("compare context %+v", c)
+	}
+	if len(callers) != 1 || callers[0].Source != "mirror" || callers[0].FilePath != "app/Launcher.java" {
+		t.Fatalf("callers %+v", callers)
+	}
+
+	// Change 123 belongs to linux, which is not mirrored.
+	_, out = invokeNode(t, ctx, map[string]any{"changeNum": "123", "patchset": "2"}, "context")
+	for _, c := range out.(ContextOutput).Ctxs {
+		if c.Source == "mirror" {
+			t.Fatalf("unlisted project read from the mirror: %+v", c)
+		}
+	}
+}
+
 func TestBuildReactGraph(t *testing.T) {
 	os.Setenv("GERRIT_BASE_URL", "")
 	g, err := BuildReactGraph()
@@ -1,13 +1,56 @@
 package flows
 
 import (
-    "context"
-    "eino-gerrit-review/internal/app/eino/core"
+	"context"
+	"eino-gerrit-review/internal/app/eino/core"
+	"eino-gerrit-review/internal/app/tools"
 )
 
+// IngestFlow lists open Gerrit changes matching fc.Data["query"] (a tools.ChangeQuery)
+// and annotates each one with the latest review we have stored for it.
 type IngestFlow struct{}
 
+// ChangeView is a change summary plus our own review status for it.
+type ChangeView struct {
+	tools.ChangeSummary
+	// ReviewStatus is one of none, previewed, published or publish_failed.
+	ReviewStatus     string `json:"reviewStatus"`
+	ReviewID         string `json:"reviewId,omitempty"`
+	ReviewedPatchset string `json:"reviewedPatchset,omitempty"`
+}
+
 func (f *IngestFlow) Execute(ctx context.Context, fc *core.FlowContext) (core.Result, error) {
-    return core.Result{"changes": []map[string]interface{}{}}, nil
+	q, _ := fc.Data["query"].(tools.ChangeQuery)
+	limit, _ := fc.Data["limit"].(int)
+	if limit <= 0 {
+		limit = 25
+	}
+	raw, err := (&tools.GerritTool{}).QueryChanges(q, limit)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]ChangeView, 0, len(raw))
+	for _, c := range raw {
+		v := ChangeView{ChangeSummary: tools.SummarizeChange(c), ReviewStatus: "none"}
+		if v.Number != "" {
+			if rs := core.ListReviews(core.ReviewFilter{ChangeNum: v.Number, Limit: 1}); len(rs) > 0 {
+				v.ReviewID = rs[0].ID
+				v.ReviewedPatchset = rs[0].Patchset
+				v.ReviewStatus = reviewStatus(rs[0])
+			}
+		}
+		changes = append(changes, v)
+	}
+	return core.Result{"changes": changes}, nil
 }
 
+func reviewStatus(r core.ReviewStored) string {
+	switch {
+	case r.Published:
+		return "published"
+	case r.PublishError != "":
+		return "publish_failed"
+	default:
+		return "previewed"
+	}
+}
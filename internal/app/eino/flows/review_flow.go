@@ -4,6 +4,7 @@ import (
 	"context"
 	einoGraph "eino-gerrit-review/internal/app/eino"
 	"eino-gerrit-review/internal/app/eino/core"
+	"eino-gerrit-review/internal/app/tools"
 	"eino-gerrit-review/internal/monitor"
 	"time"
 
@@ -32,5 +33,5 @@ func (f *ReviewFlow) Execute(ctx context.Context, fc *core.FlowContext) (core.Re
 		return nil, err
 	}
 	monitor.IncCall()
-	return core.Result{"reviewId": "R0001", "preview": out["preview"]}, nil
+	return core.Result{"reviewId": "R0001", "preview": out["preview"], "model": tools.ModelName()}, nil
 }
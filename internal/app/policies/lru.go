@@ -0,0 +1,142 @@
+package policies
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheStats are the counters of one LRU cache.
+type CacheStats struct {
+	Size       int     `json:"size"`
+	Capacity   int     `json:"capacity"`
+	TTLSeconds float64 `json:"ttl_seconds"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	Evictions  uint64  `json:"evictions"`
+	Expired    uint64  `json:"expired"`
+	// Bytes and MaxBytes are only set for caches bounded by size.
+	Bytes    int `json:"bytes,omitempty"`
+	MaxBytes int `json:"max_bytes,omitempty"`
+}
+
+// LRU is a cache holding at most a fixed number of entries, and optionally of
+// bytes, dropping the least recently used ones to make room. Entries also
+// expire ttl after they were added; a ttl <= 0 keeps them until evicted. It
+// is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	max      int
+	maxBytes int
+	size     func(V) int
+	bytes    int
+	ttl      time.Duration
+	ll       *list.List // front is most recently used
+	items    map[K]*list.Element
+	stats    CacheStats
+	now      func() time.Time
+}
+
+type lruEntry[K comparable, V any] struct {
+	key  K
+	val  V
+	size int
+	exp  time.Time
+}
+
+// NewLRU returns an empty cache of max entries, at least one.
+func NewLRU[K comparable, V any](max int, ttl time.Duration) *LRU[K, V] {
+	if max < 1 {
+		max = 1
+	}
+	return &LRU[K, V]{max: max, ttl: ttl, ll: list.New(), items: make(map[K]*list.Element), now: time.Now}
+}
+
+// NewSizedLRU is NewLRU that also holds at most maxBytes, as measured by size.
+// A value larger than maxBytes is never stored.
+func NewSizedLRU[K comparable, V any](max, maxBytes int, ttl time.Duration, size func(V) int) *LRU[K, V] {
+	c := NewLRU[K, V](max, ttl)
+	if maxBytes > 0 && size != nil {
+		c.maxBytes, c.size = maxBytes, size
+	}
+	return c
+}
+
+// Get returns the live value for k and marks it recently used.
+func (c *LRU[K, V]) Get(k K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	el, ok := c.items[k]
+	if !ok {
+		c.stats.Misses++
+		return zero, false
+	}
+	e := el.Value.(*lruEntry[K, V])
+	if c.ttl > 0 && !c.now().Before(e.exp) {
+		c.remove(el)
+		c.stats.Expired++
+		c.stats.Misses++
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+	return e.val, true
+}
+
+// Add stores v under k, evicting the least recently used entries when full.
+func (c *LRU[K, V]) Add(k K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	if c.size != nil {
+		n = c.size(v)
+	}
+	if el, ok := c.items[k]; ok {
+		c.remove(el)
+	}
+	if c.maxBytes > 0 && n > c.maxBytes {
+		return
+	}
+	c.items[k] = c.ll.PushFront(&lruEntry[K, V]{key: k, val: v, size: n, exp: c.now().Add(c.ttl)})
+	c.bytes += n
+	for c.ll.Len() > c.max || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// RemoveIf drops every entry whose key satisfies fn and returns how many.
+func (c *LRU[K, V]) RemoveIf(fn func(K) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if fn(el.Value.(*lruEntry[K, V]).key) {
+			c.remove(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (c *LRU[K, V]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Size, s.Capacity, s.TTLSeconds = c.ll.Len(), c.max, c.ttl.Seconds()
+	if c.maxBytes > 0 {
+		s.Bytes, s.MaxBytes = c.bytes, c.maxBytes
+	}
+	return s
+}
+
+func (c *LRU[K, V]) remove(el *list.Element) {
+	e := el.Value.(*lruEntry[K, V])
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
@@ -0,0 +1,59 @@
+package policies
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewLRU[string, int](2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("a missing")
+	}
+	// "b" is now the least recently used.
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("b should have been evicted")
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Fatalf("c = %d, %v", v, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("a should have expired")
+	}
+	c.Add("x1", 0)
+	c.Add("x2", 0)
+	if n := c.RemoveIf(func(k string) bool { return k[0] == 'x' }); n != 2 {
+		t.Fatalf("removed %d", n)
+	}
+
+	want := CacheStats{Size: 0, Capacity: 2, TTLSeconds: 60, Hits: 2, Misses: 2, Evictions: 2, Expired: 1}
+	if got := c.Stats(); got != want {
+		t.Fatalf("stats %+v, want %+v", got, want)
+	}
+}
+
+func TestSizedLRU(t *testing.T) {
+	c := NewSizedLRU[string, string](10, 10, 0, func(s string) int { return len(s) })
+	c.Add("a", "1234")
+	c.Add("b", "1234")
+	c.Add("c", "1234") // 12 bytes: "a" goes
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("a should have been evicted")
+	}
+	c.Add("b", "12") // replacing a value frees its bytes
+	c.Add("big", "12345678901")
+	if _, ok := c.Get("big"); ok {
+		t.Fatalf("values over the byte limit must not be stored")
+	}
+	if got := c.Stats(); got.Size != 2 || got.Bytes != 6 || got.MaxBytes != 10 || got.Evictions != 1 {
+		t.Fatalf("stats %+v", got)
+	}
+}
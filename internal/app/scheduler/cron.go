@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule yields the next run time after a given instant.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) Next(after time.Time) time.Time { return after.Add(time.Duration(s)) }
+
+func (s intervalSchedule) String() string { return "every " + time.Duration(s).String() }
+
+// cronSchedule is a standard five-field cron expression evaluated in local time.
+type cronSchedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record a "*" day field; when both day fields are
+	// restricted a time matching either of them runs, as in cron(8).
+	domAny, dowAny bool
+}
+
+func (s *cronSchedule) String() string { return s.expr }
+
+var cronAliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// ParseCron parses "minute hour day-of-month month day-of-week" with *, lists,
+// ranges and steps, plus the @hourly, @daily, @weekly and @monthly shorthands.
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	spec := expr
+	if a, ok := cronAliases[spec]; ok {
+		spec = a
+	}
+	f := strings.Fields(spec)
+	if len(f) != 5 {
+		return nil, errors.New("cron: expected 5 fields in " + strconv.Quote(expr))
+	}
+	s := &cronSchedule{expr: expr, domAny: strings.HasPrefix(f[2], "*"), dowAny: strings.HasPrefix(f[4], "*")}
+	var err error
+	if s.minute, err = cronField(f[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if s.hour, err = cronField(f[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if s.dom, err = cronField(f[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if s.month, err = cronField(f[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if s.dow, err = cronField(f[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// Both 0 and 7 mean Sunday.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func cronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if r, st, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(st)
+			if err != nil || n <= 0 {
+				return 0, errors.New("cron: invalid step in " + strconv.Quote(part))
+			}
+			rng, step = r, n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(a)
+			hi = lo
+			if isRange {
+				hi, err2 = strconv.Atoi(b)
+			} else if step > 1 {
+				hi = max
+			}
+			if err1 != nil || err2 != nil || lo < min || hi > max || lo > hi {
+				return 0, errors.New("cron: value out of range in " + strconv.Quote(part))
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first matching minute strictly after `after`, or the zero
+// time if none exists within five years (e.g. "0 0 30 2 *").
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			// Truncate rounds in UTC, which misses minute 0 in zones
+			// with a half-hour offset, so step in t's own zone.
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// GerritEvent is the subset of a Gerrit stream-events record that drives reviews.
+// The same JSON is delivered by `gerrit stream-events` and by the webhooks plugin.
+type GerritEvent struct {
+	Type   string `json:"type"`
+	Change struct {
+		Project string      `json:"project"`
+		Branch  string      `json:"branch"`
+		ID      string      `json:"id"`
+		Number  eventNumber `json:"number"`
+		Subject string      `json:"subject"`
+		WIP     bool        `json:"wip"`
+		Private bool        `json:"private"`
+	} `json:"change"`
+	PatchSet struct {
+		Number   eventNumber `json:"number"`
+		Revision string      `json:"revision"`
+		Ref      string      `json:"ref"`
+		Kind     string      `json:"kind"`
+	} `json:"patchSet"`
+	Author         EventAccount `json:"author"`
+	Uploader       EventAccount `json:"uploader"`
+	Comment        string       `json:"comment"`
+	EventCreatedOn int64        `json:"eventCreatedOn"`
+}
+
+type EventAccount struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+// eventNumber accepts both the numeric and the quoted form; older Gerrit
+// releases emit change and patchset numbers as strings.
+type eventNumber string
+
+func (n *eventNumber) UnmarshalJSON(b []byte) error {
+	*n = eventNumber(strings.Trim(string(b), `"`))
+	return nil
+}
+
+// EventOptions controls how events are mapped to review tasks.
+type EventOptions struct {
+	EnableContext bool
+	// TriggerPhrase in a comment-added event requests a fresh review of that patchset.
+	TriggerPhrase string
+	// BotUser is our own Gerrit account; its comments never trigger reviews.
+	BotUser string
+}
+
+// EventResult records what was done with a single event.
+type EventResult struct {
+	Type      string `json:"type"`
+	ChangeNum string `json:"changeNum,omitempty"`
+	Patchset  string `json:"patchset,omitempty"`
+	Action    string `json:"action"`
+	Reason    string `json:"reason,omitempty"`
+	JobID     string `json:"jobId,omitempty"`
+}
+
+var ErrEmptyEvent = errors.New("empty event payload")
+
+// ParseEvents decodes one event or a newline-delimited stream of events.
+func ParseEvents(r io.Reader) ([]GerritEvent, error) {
+	dec := json.NewDecoder(r)
+	var out []GerritEvent
+	for {
+		var e GerritEvent
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	if len(out) == 0 {
+		return nil, ErrEmptyEvent
+	}
+	return out, nil
+}
+
+// ParseEventBytes is ParseEvents for an in-memory payload.
+func ParseEventBytes(b []byte) ([]GerritEvent, error) { return ParseEvents(bytes.NewReader(b)) }
+
+// ToTask maps an event to the review it asks for. When no review is needed the
+// returned reason explains why.
+func (e GerritEvent) ToTask(opts EventOptions) (Task, bool, string) {
+	t := Task{ChangeNum: string(e.Change.Number), Patchset: string(e.PatchSet.Number), EnableContext: opts.EnableContext}
+	switch e.Type {
+	case "patchset-created":
+		if e.Change.WIP || e.Change.Private {
+			return Task{}, false, "work in progress or private change"
+		}
+		if e.PatchSet.Kind == "NO_CHANGE" || e.PatchSet.Kind == "NO_CODE_CHANGE" {
+			return Task{}, false, "patchset has no code change"
+		}
+	case "comment-added":
+		if opts.TriggerPhrase == "" || !strings.Contains(e.Comment, opts.TriggerPhrase) {
+			return Task{}, false, "comment does not request a review"
+		}
+		if opts.BotUser != "" && e.Author.Username == opts.BotUser {
+			return Task{}, false, "comment posted by the review bot"
+		}
+	case "change-merged":
+		return Task{}, false, "change merged"
+	default:
+		return Task{}, false, "unsupported event type"
+	}
+	if t.ChangeNum == "" || t.Patchset == "" {
+		return Task{}, false, "missing change or patchset number"
+	}
+	return t, true, ""
+}
+
+// DispatchEvents queues a review task for every event that asks for one.
+func DispatchEvents(events []GerritEvent, opts EventOptions, pool *WorkerPool) []EventResult {
+	out := make([]EventResult, 0, len(events))
+	for _, e := range events {
+		res := EventResult{Type: e.Type, ChangeNum: string(e.Change.Number), Patchset: string(e.PatchSet.Number)}
+		t, ok, reason := e.ToTask(opts)
+		if !ok {
+			res.Action, res.Reason = "ignored", reason
+			out = append(out, res)
+			continue
+		}
+		if e.Type == "patchset-created" && Reviewed(t.ChangeNum, t.Patchset) {
+			res.Action, res.Reason = "ignored", "patchset already reviewed"
+			out = append(out, res)
+			continue
+		}
+		j, err := pool.SubmitJob(t)
+		res.JobID = j.ID
+		if err != nil {
+			res.Action, res.Reason = "rejected", err.Error()
+			if errors.Is(err, ErrDuplicateTask) || errors.Is(err, ErrStaleTask) {
+				res.Action = "ignored"
+			}
+			out = append(out, res)
+			continue
+		}
+		res.Action = "queued"
+		out = append(out, res)
+	}
+	return out
+}
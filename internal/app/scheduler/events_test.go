@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"eino-gerrit-review/internal/app/eino/core"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// replayFixture feeds a recorded Gerrit event file through the dispatcher
+// and returns the results together with the tasks that reached the pool.
+func replayFixture(t *testing.T, name string, opts EventOptions) ([]EventResult, []Task) {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "events", name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	events, err := ParseEventBytes(b)
+	if err != nil {
+		t.Fatalf("parse %s: %v", name, err)
+	}
+	pool := NewWorkerPool(1, len(events))
+	res := DispatchEvents(events, opts, pool)
+	var tasks []Task
+	for len(pool.ch) > 0 {
+		tasks = append(tasks, (<-pool.ch).task)
+	}
+	return res, tasks
+}
+
+func TestEventFixtures(t *testing.T) {
+	opts := EventOptions{EnableContext: true, TriggerPhrase: "/ai-review", BotUser: "review-bot"}
+	cases := []struct {
+		fixture string
+		actions []string
+		tasks   []Task
+	}{
+		{"patchset_created.json", []string{"queued"}, []Task{{ChangeNum: "12345", Patchset: "3", EnableContext: true}}},
+		{"patchset_created_legacy.json", []string{"queued"}, []Task{{ChangeNum: "12347", Patchset: "2", EnableContext: true}}},
+		{"patchset_created_wip.json", []string{"ignored"}, nil},
+		{"patchset_created_no_code_change.json", []string{"ignored"}, nil},
+		{"comment_added_trigger.json", []string{"queued"}, []Task{{ChangeNum: "12345", Patchset: "3", EnableContext: true}}},
+		{"comment_added_plain.json", []string{"ignored"}, nil},
+		{"comment_added_bot.json", []string{"ignored"}, nil},
+		{"change_merged.json", []string{"ignored"}, nil},
+		{"ref_updated.json", []string{"ignored"}, nil},
+		{"stream.ndjson", []string{"queued", "ignored", "ignored"}, []Task{{ChangeNum: "12347", Patchset: "2", EnableContext: true}}},
+	}
+	for _, c := range cases {
+		t.Run(c.fixture, func(t *testing.T) {
+			core.SetReviewStore(core.NewMemoryReviewStore(0))
+			res, tasks := replayFixture(t, c.fixture, opts)
+			if len(res) != len(c.actions) {
+				t.Fatalf("got %d results, want %d", len(res), len(c.actions))
+			}
+			for i, a := range c.actions {
+				if res[i].Action != a {
+					t.Fatalf("event %d: action %q (%s), want %q", i, res[i].Action, res[i].Reason, a)
+				}
+			}
+			if len(tasks) != len(c.tasks) {
+				t.Fatalf("got tasks %+v, want %+v", tasks, c.tasks)
+			}
+			for i := range tasks {
+				if tasks[i] != c.tasks[i] {
+					t.Fatalf("task %d = %+v, want %+v", i, tasks[i], c.tasks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPatchsetCreatedSkipsReviewed(t *testing.T) {
+	core.SetReviewStore(core.NewMemoryReviewStore(0))
+	_ = core.PutReview(core.ReviewStored{ID: "S-12345-3", ChangeNum: "12345", Patchset: "3"})
+	res, tasks := replayFixture(t, "patchset_created.json", EventOptions{})
+	if len(tasks) != 0 || res[0].Action != "ignored" {
+		t.Fatalf("reviewed patchset was queued again: %+v", res)
+	}
+}
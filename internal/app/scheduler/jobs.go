@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// Job is the lifecycle record of one submitted Task.
+type Job struct {
+	ID            string    `json:"id"`
+	ChangeNum     string    `json:"changeNum"`
+	Patchset      string    `json:"patchset"`
+	EnableContext bool      `json:"enableContext"`
+	AutoPublish   bool      `json:"autoPublish"`
+	State         JobState  `json:"state"`
+	Error         string    `json:"error,omitempty"`
+	ReviewID      string    `json:"reviewId,omitempty"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	CreatedAt     time.Time `json:"createdAt"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	DurationMs    int64     `json:"durationMs"`
+
+	task Task
+	// cancel aborts the job while it is running.
+	cancel context.CancelFunc
+}
+
+func (j *Job) finished() bool {
+	return j.State == JobSucceeded || j.State == JobFailed || j.State == JobCancelled
+}
+
+// JobFilter narrows ListJobs. Empty fields match everything.
+type JobFilter struct {
+	State     JobState
+	ChangeNum string
+	Limit     int
+}
+
+// jobRegistry keeps every live job and the most recent finished ones.
+// Callers hold WorkerPool.mu while using it.
+type jobRegistry struct {
+	jobs   map[string]*Job
+	order  []string
+	keep   int
+	lastID int64
+}
+
+func newJobRegistry(keep int) *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*Job), keep: keep}
+}
+
+func (r *jobRegistry) add(t Task) *Job {
+	id := time.Now().UnixNano()
+	if id <= r.lastID {
+		id = r.lastID + 1
+	}
+	r.lastID = id
+	j := &Job{
+		ID:            "J" + strconv.FormatInt(id, 10),
+		ChangeNum:     t.ChangeNum,
+		Patchset:      t.Patchset,
+		EnableContext: t.EnableContext,
+		AutoPublish:   t.AutoPublish,
+		State:         JobQueued,
+		CreatedAt:     time.Now(),
+		task:          t,
+	}
+	r.jobs[j.ID] = j
+	r.order = append(r.order, j.ID)
+	r.prune()
+	return j
+}
+
+// prune drops the oldest finished jobs once more than keep are held.
+func (r *jobRegistry) prune() {
+	if r.keep <= 0 || len(r.order) <= r.keep {
+		return
+	}
+	excess := len(r.order) - r.keep
+	kept := r.order[:0]
+	for _, id := range r.order {
+		if excess > 0 && r.jobs[id].finished() {
+			delete(r.jobs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	r.order = kept
+}
+
+func (r *jobRegistry) list(f JobFilter) []Job {
+	out := make([]Job, 0)
+	for i := len(r.order) - 1; i >= 0; i-- {
+		j := r.jobs[r.order[i]]
+		if f.State != "" && j.State != f.State {
+			continue
+		}
+		if f.ChangeNum != "" && j.ChangeNum != f.ChangeNum {
+			continue
+		}
+		out = append(out, *j)
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+	}
+	return out
+}
+
+func (j *Job) start(cancel context.CancelFunc) {
+	j.State = JobRunning
+	j.Attempts++
+	j.StartedAt = time.Now()
+	j.NextAttemptAt = time.Time{}
+	j.cancel = cancel
+}
+
+func (j *Job) finish(state JobState, msg string) {
+	j.State = state
+	j.Error = msg
+	j.FinishedAt = time.Now()
+	if !j.StartedAt.IsZero() {
+		j.DurationMs = j.FinishedAt.Sub(j.StartedAt).Milliseconds()
+	}
+	j.cancel = nil
+}
+
+// GetJob returns a snapshot of the job with the given id.
+func (p *WorkerPool) GetJob(id string) (Job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, ok := p.jobs.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// ListJobs returns job snapshots, newest first.
+func (p *WorkerPool) ListJobs(f JobFilter) []Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.jobs.list(f)
+}
+
+// CancelJob cancels a queued job or aborts a running one.
+func (p *WorkerPool) CancelJob(id string) (Job, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, ok := p.jobs.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	if j.finished() {
+		return *j, ErrJobFinished
+	}
+	if j.State == JobRunning && j.cancel != nil {
+		j.cancel()
+	}
+	// Queued jobs are skipped by the worker; running ones stop at the next
+	// context check and keep this state when they return.
+	j.finish(JobCancelled, "cancelled by user")
+	return *j, nil
+}
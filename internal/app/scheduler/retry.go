@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"eino-gerrit-review/internal/app/tools"
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides whether and when a failed task runs again. Only errors
+// with a class from tools.ErrorClass are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of runs per task, including the first.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// ClassAttempts overrides MaxAttempts for a single error class.
+	ClassAttempts map[string]int
+}
+
+// DefaultRetryPolicy gives rate limits more room than other transient errors.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:   3,
+		BaseDelay:     2 * time.Second,
+		MaxDelay:      time.Minute,
+		ClassAttempts: map[string]int{tools.ClassRateLimited: 5},
+	}
+}
+
+// ParseClassAttempts reads overrides in the form "rate_limited=5,model_timeout=2".
+func ParseClassAttempts(s string) (map[string]int, error) {
+	out := make(map[string]int)
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, errors.New("invalid retry override: " + kv)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < 0 {
+			return nil, errors.New("invalid retry attempts: " + kv)
+		}
+		out[strings.TrimSpace(k)] = n
+	}
+	return out, nil
+}
+
+func (r RetryPolicy) attempts(class string) int {
+	if n, ok := r.ClassAttempts[class]; ok {
+		return n
+	}
+	return r.MaxAttempts
+}
+
+// Next returns the delay before the next run of a task that has already run
+// `attempts` times and failed with an error of the given class.
+func (r RetryPolicy) Next(class string, attempts int) (time.Duration, bool) {
+	if class == "" || attempts >= r.attempts(class) {
+		return 0, false
+	}
+	d := r.BaseDelay
+	for i := 1; i < attempts && d < r.MaxDelay; i++ {
+		d *= 2
+	}
+	if r.MaxDelay > 0 && d > r.MaxDelay {
+		d = r.MaxDelay
+	}
+	if d <= 0 {
+		return 0, true
+	}
+	// Equal jitter: keep half the backoff and randomize the rest so that tasks
+	// failing together do not hit Gerrit or the model together again.
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1)), true
+}
+
+// DeadLetter is a job that failed for good.
+type DeadLetter struct {
+	Job
+	ErrorClass string    `json:"errorClass,omitempty"`
+	DeadAt     time.Time `json:"deadAt"`
+}
+
+// deadLetters keeps the most recent permanently failed jobs. Callers hold WorkerPool.mu.
+type deadLetters struct {
+	items map[string]DeadLetter
+	order []string
+	keep  int
+}
+
+func newDeadLetters(keep int) *deadLetters {
+	return &deadLetters{items: make(map[string]DeadLetter), keep: keep}
+}
+
+func (d *deadLetters) add(j Job, class string) {
+	d.items[j.ID] = DeadLetter{Job: j, ErrorClass: class, DeadAt: time.Now()}
+	d.order = append(d.order, j.ID)
+	for d.keep > 0 && len(d.order) > d.keep {
+		delete(d.items, d.order[0])
+		d.order = d.order[1:]
+	}
+}
+
+func (d *deadLetters) remove(id string) (DeadLetter, bool) {
+	dl, ok := d.items[id]
+	if !ok {
+		return DeadLetter{}, false
+	}
+	delete(d.items, id)
+	for i, v := range d.order {
+		if v == id {
+			d.order = append(d.order[:i], d.order[i+1:]...)
+			break
+		}
+	}
+	return dl, true
+}
+
+func (d *deadLetters) list() []DeadLetter {
+	out := make([]DeadLetter, 0, len(d.order))
+	for i := len(d.order) - 1; i >= 0; i-- {
+		out = append(out, d.items[d.order[i]])
+	}
+	return out
+}
+
+// SetRetryPolicy replaces the retry policy; call it before Run.
+func (p *WorkerPool) SetRetryPolicy(r RetryPolicy) { p.retry = r }
+
+// DeadLetters lists permanently failed jobs, newest first.
+func (p *WorkerPool) DeadLetters() []DeadLetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dead.list()
+}
+
+// Requeue submits the task of a dead-lettered job again as a new job.
+func (p *WorkerPool) Requeue(id string) (Job, error) {
+	p.mu.Lock()
+	dl, ok := p.dead.items[id]
+	p.mu.Unlock()
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	j, err := p.SubmitJob(dl.task)
+	if err != nil {
+		return j, err
+	}
+	p.mu.Lock()
+	p.dead.remove(id)
+	p.mu.Unlock()
+	return j, nil
+}
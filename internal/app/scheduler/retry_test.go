@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"context"
+	"eino-gerrit-review/internal/app/tools"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	r := RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond} {
+		d, ok := r.Next(tools.ClassNetwork, attempt)
+		if !ok || d < max/2 || d > max {
+			t.Fatalf("attempt %d: delay %s ok=%v, want within [%s,%s]", attempt, d, ok, max/2, max)
+		}
+	}
+	if _, ok := r.Next(tools.ClassNetwork, 4); ok {
+		t.Fatalf("attempts exhausted but retry allowed")
+	}
+	if _, ok := r.Next("", 1); ok {
+		t.Fatalf("unclassified error must not be retried")
+	}
+	cls, err := ParseClassAttempts("rate_limited=6, model_timeout=1")
+	if err != nil || cls[tools.ClassRateLimited] != 6 || cls[tools.ClassModelTimeout] != 1 {
+		t.Fatalf("parse: %v %v", cls, err)
+	}
+	if _, err := ParseClassAttempts("network"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
+
+func waitJob(t *testing.T, p *WorkerPool, id string, want JobState) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, _ := p.GetJob(id); j.State == want {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	j, _ := p.GetJob(id)
+	t.Fatalf("job %s state %s, want %s", id, j.State, want)
+	return j
+}
+
+func TestWorkerPoolRetriesTransientErrors(t *testing.T) {
+	p := NewWorkerPool(2, 10)
+	p.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	var calls int32
+	p.exec = func(ctx context.Context, t Task) (string, error) {
+		switch t.ChangeNum {
+		case "1":
+			if atomic.AddInt32(&calls, 1) < 3 {
+				return "", fmt.Errorf("llm: %w", tools.ErrRateLimited)
+			}
+			return "S-1-1", nil
+		case "2":
+			return "", fmt.Errorf("gerrit: %w", tools.ErrGerritUnavailable)
+		}
+		return "", errors.New("invalid diff")
+	}
+	p.Run(context.Background())
+	flaky, _ := p.SubmitJob(Task{ChangeNum: "1", Patchset: "1"})
+	down, _ := p.SubmitJob(Task{ChangeNum: "2", Patchset: "1"})
+	broken, _ := p.SubmitJob(Task{ChangeNum: "3", Patchset: "1"})
+
+	if j := waitJob(t, p, flaky.ID, JobSucceeded); j.Attempts != 3 {
+		t.Fatalf("flaky job attempts = %d", j.Attempts)
+	}
+	if j := waitJob(t, p, down.ID, JobFailed); j.Attempts != 3 {
+		t.Fatalf("unavailable job attempts = %d", j.Attempts)
+	}
+	if j := waitJob(t, p, broken.ID, JobFailed); j.Attempts != 1 {
+		t.Fatalf("permanent failure retried: %d", j.Attempts)
+	}
+
+	dead := p.DeadLetters()
+	if len(dead) != 2 {
+		t.Fatalf("expected 2 dead letters, got %+v", dead)
+	}
+	for _, d := range dead {
+		if d.ID == down.ID && d.ErrorClass != tools.ClassGerritUnavailable {
+			t.Fatalf("wrong class: %+v", d)
+		}
+	}
+	again, err := p.Requeue(down.ID)
+	if err != nil || again.ID == down.ID || again.State != JobQueued {
+		t.Fatalf("requeue: %+v %v", again, err)
+	}
+	if len(p.DeadLetters()) != 1 {
+		t.Fatalf("requeued job still dead-lettered")
+	}
+	if _, err := p.Requeue("nope"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"eino-gerrit-review/internal/app/eino/core"
+	"eino-gerrit-review/internal/app/tools"
+)
+
+// PendingTasks turns a Gerrit change listing into review tasks for each change's
+// current patchset, leaving out patchsets that already have a stored review.
+func PendingTasks(changes []map[string]interface{}, enableContext bool) []Task {
+	out := make([]Task, 0, len(changes))
+	for _, c := range changes {
+		s := tools.SummarizeChange(c)
+		if s.Number == "" || s.CurrentPatchset == "" {
+			continue
+		}
+		if Reviewed(s.Number, s.CurrentPatchset) {
+			continue
+		}
+		out = append(out, Task{ChangeNum: s.Number, Patchset: s.CurrentPatchset, EnableContext: enableContext})
+	}
+	return out
+}
+
+// Reviewed reports whether a review for the given patchset is already stored.
+func Reviewed(changeNum, patchset string) bool {
+	return len(core.ListReviews(core.ReviewFilter{ChangeNum: changeNum, Patchset: patchset, Limit: 1})) > 0
+}
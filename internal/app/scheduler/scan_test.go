@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"eino-gerrit-review/internal/app/eino/core"
+	"testing"
+)
+
+func TestPendingTasksUsesCurrentPatchset(t *testing.T) {
+	core.SetReviewStore(core.NewMemoryReviewStore(0))
+	change := func(num, ps float64) map[string]interface{} {
+		return map[string]interface{}{
+			"_number": num, "current_revision": "rev",
+			"revisions": map[string]interface{}{"rev": map[string]interface{}{"_number": ps}},
+		}
+	}
+	_ = core.PutReview(core.ReviewStored{ID: "S-10-3", ChangeNum: "10", Patchset: "3"})
+
+	tasks := PendingTasks([]map[string]interface{}{change(10, 3), change(11, 5), {"_number": float64(12)}}, true)
+	if len(tasks) != 1 {
+		t.Fatalf("expected 1 task, got %+v", tasks)
+	}
+	if tasks[0].ChangeNum != "11" || tasks[0].Patchset != "5" || !tasks[0].EnableContext {
+		t.Fatalf("unexpected task: %+v", tasks[0])
+	}
+}
@@ -1,36 +1,309 @@
 package scheduler
 
 import (
+	"bytes"
 	"context"
 	"eino-gerrit-review/internal/app/tools"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
 )
 
+// WatchEntry is one scheduled scan of open changes. Exactly one of Interval
+// (a Go duration such as "15m") and Cron must be set.
+type WatchEntry struct {
+	Name          string `json:"name"`
+	Project       string `json:"project"`
+	Branch        string `json:"branch"`
+	Query         string `json:"query"`
+	Interval      string `json:"interval"`
+	Cron          string `json:"cron"`
+	Limit         int    `json:"limit"`
+	EnableContext bool   `json:"enableContext"`
+	AutoPublish   bool   `json:"autoPublish"`
+}
+
+// minWatchInterval keeps a typo like "1s" from hammering Gerrit.
+const minWatchInterval = 10 * time.Second
+
+func (e WatchEntry) schedule() (Schedule, error) {
+	switch {
+	case e.Interval != "" && e.Cron != "":
+		return nil, errors.New("set either interval or cron, not both")
+	case e.Interval != "":
+		d, err := time.ParseDuration(e.Interval)
+		if err != nil {
+			return nil, err
+		}
+		if d < minWatchInterval {
+			return nil, fmt.Errorf("interval must be at least %s", minWatchInterval)
+		}
+		return intervalSchedule(d), nil
+	case e.Cron != "":
+		return ParseCron(e.Cron)
+	}
+	return nil, errors.New("missing interval or cron")
+}
+
+// ParseWatches decodes a watch file, {"watches": [...]} in JSON or the same
+// structure in YAML, and validates every entry. Entries without a name are
+// named after their project and branch.
+func ParseWatches(b []byte, yaml bool) ([]WatchEntry, error) {
+	if yaml {
+		j, err := gjson.LoadYaml(b)
+		if err != nil {
+			return nil, err
+		}
+		if b, err = j.ToJson(); err != nil {
+			return nil, err
+		}
+	}
+	var file struct {
+		Watches []WatchEntry `json:"watches"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(file.Watches))
+	for i := range file.Watches {
+		e := &file.Watches[i]
+		if e.Name == "" {
+			e.Name = strings.Trim(e.Project+"/"+e.Branch, "/")
+		}
+		if e.Name == "" {
+			return nil, fmt.Errorf("watch %d: missing name, project or query", i)
+		}
+		if seen[e.Name] {
+			return nil, fmt.Errorf("watch %q: duplicate name", e.Name)
+		}
+		seen[e.Name] = true
+		if e.Project == "" && e.Query == "" {
+			return nil, fmt.Errorf("watch %q: missing project or query", e.Name)
+		}
+		if _, err := e.schedule(); err != nil {
+			return nil, fmt.Errorf("watch %q: %v", e.Name, err)
+		}
+		if e.Limit <= 0 {
+			e.Limit = 10
+		}
+	}
+	return file.Watches, nil
+}
+
+// WatchStatus is a snapshot of a watcher for the API.
+type WatchStatus struct {
+	WatchEntry
+	Schedule    string    `json:"schedule"`
+	LastRun     time.Time `json:"lastRun"`
+	NextRun     time.Time `json:"nextRun"`
+	LastQueued  int       `json:"lastQueued"`
+	LastSkipped int       `json:"lastSkipped"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// Watcher periodically queues reviews for the open changes matched by one entry.
 type Watcher struct {
-	Ticker *time.Ticker
+	entry WatchEntry
+	sched Schedule
+	pool  *WorkerPool
+
+	mu     sync.Mutex
+	status WatchStatus
+	cancel context.CancelFunc
 }
 
-func NewWatcher(d time.Duration) *Watcher { return &Watcher{Ticker: time.NewTicker(d)} }
+func NewWatcher(e WatchEntry, pool *WorkerPool) (*Watcher, error) {
+	sched, err := e.schedule()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{entry: e, sched: sched, pool: pool}
+	w.status = WatchStatus{WatchEntry: e, Schedule: fmt.Sprint(sched)}
+	return w, nil
+}
 
-func (w *Watcher) Run(ctx context.Context, project, branch string, pool *WorkerPool, enableContext bool) {
-	gt := &tools.GerritTool{}
+// Run scans on every scheduled tick until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
 	for {
+		next := w.sched.Next(time.Now())
+		w.mu.Lock()
+		w.status.NextRun = next
+		if next.IsZero() {
+			w.status.LastError = "schedule never fires"
+		}
+		w.mu.Unlock()
+		if next.IsZero() {
+			return
+		}
+		t := time.NewTimer(time.Until(next))
 		select {
 		case <-ctx.Done():
+			t.Stop()
 			return
-		case <-w.Ticker.C:
-			changes, _ := gt.GetOpenChanges(project, branch, 10)
-			for _, c := range changes {
-				// Use _number field from Gerrit API response as the unique identifier
-				num := ""
-				if n, ok := c["_number"].(float64); ok {
-					num = fmt.Sprintf("%.0f", n)
-				}
-				if num != "" {
-					pool.Submit(Task{ChangeNum: num, Patchset: "1", EnableContext: enableContext})
-				}
+		case <-t.C:
+			if err := w.Scan(); err != nil {
+				log.Printf("watch %s: %v", w.entry.Name, err)
 			}
 		}
 	}
 }
+
+// Scan queries Gerrit once and submits every unreviewed current patchset.
+func (w *Watcher) Scan() error {
+	e := w.entry
+	changes, err := (&tools.GerritTool{}).QueryChanges(tools.ChangeQuery{Project: e.Project, Branch: e.Branch, Query: e.Query}, e.Limit)
+	queued, skipped := 0, 0
+	if err == nil {
+		tasks := PendingTasks(changes, e.EnableContext)
+		skipped = len(changes) - len(tasks)
+		var rejected []string
+		for _, t := range tasks {
+			t.AutoPublish = e.AutoPublish
+			switch err := w.pool.Submit(t); {
+			case err == nil:
+				queued++
+			case errors.Is(err, ErrDuplicateTask) || errors.Is(err, ErrStaleTask):
+				skipped++
+			default:
+				rejected = append(rejected, t.ChangeNum+": "+err.Error())
+			}
+		}
+		if len(rejected) > 0 {
+			err = errors.New("rejected " + strings.Join(rejected, "; "))
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.LastRun = time.Now()
+	w.status.LastQueued = queued
+	w.status.LastSkipped = skipped
+	w.status.LastError = ""
+	if err != nil {
+		w.status.LastError = err.Error()
+	}
+	return err
+}
+
+func (w *Watcher) Status() WatchStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// WatchManager runs one Watcher per entry of a watch file and applies edits to
+// the file without a restart.
+type WatchManager struct {
+	path string
+	pool *WorkerPool
+
+	mu       sync.Mutex
+	watchers map[string]*Watcher
+	content  []byte
+	loaded   bool
+	loadErr  string
+}
+
+func NewWatchManager(path string, pool *WorkerPool) *WatchManager {
+	return &WatchManager{path: path, pool: pool, watchers: make(map[string]*Watcher)}
+}
+
+// Run loads the watch file every poll interval until ctx is cancelled.
+func (m *WatchManager) Run(ctx context.Context, poll time.Duration) {
+	t := time.NewTicker(poll)
+	defer t.Stop()
+	for {
+		if err := m.Reload(ctx); err != nil {
+			log.Printf("watch config %s: %v", m.path, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Reload re-reads the watch file and, if it changed, starts new entries, restarts
+// edited ones and stops removed ones. Unchanged watchers keep running. A file that
+// fails to parse leaves the current watchers in place.
+func (m *WatchManager) Reload(ctx context.Context) error {
+	b, err := os.ReadFile(m.path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil && m.loaded && m.loadErr == "" && bytes.Equal(b, m.content) {
+		return nil
+	}
+	var entries []WatchEntry
+	if err == nil {
+		ext := strings.ToLower(filepath.Ext(m.path))
+		entries, err = ParseWatches(b, ext == ".yaml" || ext == ".yml")
+	}
+	if err != nil {
+		// Only report a broken file once, not on every poll.
+		repeated := m.loadErr == err.Error()
+		m.loadErr = err.Error()
+		if repeated {
+			return nil
+		}
+		return err
+	}
+	m.content = b
+	m.loaded = true
+	m.loadErr = ""
+
+	keep := make(map[string]*Watcher, len(entries))
+	for _, e := range entries {
+		if w := m.watchers[e.Name]; w != nil && w.entry == e {
+			keep[e.Name] = w
+			delete(m.watchers, e.Name)
+			continue
+		}
+		w, err := NewWatcher(e, m.pool)
+		if err != nil {
+			// ParseWatches already validated the schedule.
+			continue
+		}
+		wctx, cancel := context.WithCancel(ctx)
+		w.cancel = cancel
+		go w.Run(wctx)
+		keep[e.Name] = w
+	}
+	for _, w := range m.watchers {
+		w.cancel()
+	}
+	m.watchers = keep
+	log.Printf("watch config %s: %d watch(es) active", m.path, len(keep))
+	return nil
+}
+
+// Watches returns the status of every active watcher, ordered by name.
+func (m *WatchManager) Watches() []WatchStatus {
+	m.mu.Lock()
+	out := make([]WatchStatus, 0, len(m.watchers))
+	for _, w := range m.watchers {
+		out = append(out, w.Status())
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// LoadError is the error from the last failed reload, or "".
+func (m *WatchManager) LoadError() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.loadErr
+}
+
+func (m *WatchManager) Path() string { return m.path }
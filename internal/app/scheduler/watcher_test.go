@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"context"
+	"eino-gerrit-review/internal/app/eino/core"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	loc := time.Local
+	ist := time.FixedZone("IST", 5*3600+30*60)
+	kathmandu := time.FixedZone("NPT", 5*3600+45*60)
+	cases := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// Friday evening rolls over to Monday morning.
+		{"*/15 9-17 * * 1-5", time.Date(2024, 3, 1, 17, 50, 0, 0, loc), time.Date(2024, 3, 4, 9, 0, 0, 0, loc)},
+		{"*/15 9-17 * * 1-5", time.Date(2024, 3, 4, 9, 0, 0, 0, loc), time.Date(2024, 3, 4, 9, 15, 0, 0, loc)},
+		{"0 0 1,15 * *", time.Date(2024, 2, 20, 8, 0, 0, 0, loc), time.Date(2024, 3, 1, 0, 0, 0, 0, loc)},
+		// Restricted day-of-month and day-of-week match either one.
+		{"30 6 13 * 5", time.Date(2024, 9, 1, 0, 0, 0, 0, loc), time.Date(2024, 9, 6, 6, 30, 0, 0, loc)},
+		{"@daily", time.Date(2024, 12, 31, 23, 59, 30, 0, loc), time.Date(2025, 1, 1, 0, 0, 0, 0, loc)},
+		{"0 12 * * 7", time.Date(2024, 3, 4, 0, 0, 0, 0, loc), time.Date(2024, 3, 10, 12, 0, 0, 0, loc)},
+		// Zones whose offset is not a whole hour still reach minute 0.
+		{"0 11 * * *", time.Date(2024, 3, 4, 9, 20, 0, 0, ist), time.Date(2024, 3, 4, 11, 0, 0, 0, ist)},
+		{"*/30 * * * *", time.Date(2024, 3, 4, 9, 50, 0, 0, kathmandu), time.Date(2024, 3, 4, 10, 0, 0, 0, kathmandu)},
+	}
+	for _, c := range cases {
+		s, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := s.Next(c.from); !got.Equal(c.want) {
+			t.Fatalf("%s after %s: got %s, want %s", c.expr, c.from, got, c.want)
+		}
+	}
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseWatches(t *testing.T) {
+	yaml := []byte(`
+watches:
+  - project: linux
+    branch: main
+    cron: "*/10 * * * *"
+    autoPublish: true
+  - name: android-topic
+    query: "project:android topic:perf"
+    interval: 15m
+    enableContext: true
+`)
+	ws, err := ParseWatches(yaml, true)
+	if err != nil {
+		t.Fatalf("parse yaml: %v", err)
+	}
+	if len(ws) != 2 || ws[0].Name != "linux/main" || !ws[0].AutoPublish || ws[0].Limit != 10 {
+		t.Fatalf("unexpected watches: %+v", ws)
+	}
+	if ws[1].Query != "project:android topic:perf" || !ws[1].EnableContext || ws[1].Interval != "15m" {
+		t.Fatalf("unexpected watch: %+v", ws[1])
+	}
+
+	for name, body := range map[string]string{
+		"no schedule":    `{"watches":[{"project":"p"}]}`,
+		"both schedules": `{"watches":[{"project":"p","interval":"1h","cron":"@daily"}]}`,
+		"too frequent":   `{"watches":[{"project":"p","interval":"1s"}]}`,
+		"duplicate":      `{"watches":[{"project":"p","interval":"1h"},{"project":"p","cron":"@hourly"}]}`,
+		"no target":      `{"watches":[{"name":"x","interval":"1h"}]}`,
+		"unknown field":  `{"watches":[{"project":"p","interval":"1h","every":"1h"}]}`,
+	} {
+		if _, err := ParseWatches([]byte(body), false); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestWatcherScanQueuesTasks(t *testing.T) {
+	t.Setenv("GERRIT_BASE_URL", "")
+	core.SetReviewStore(core.NewMemoryReviewStore(0))
+	p := NewWorkerPool(1, 10)
+	w, err := NewWatcher(WatchEntry{Name: "w", Project: "linux", Interval: "1h", Limit: 10, AutoPublish: true}, p)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	if err := w.Scan(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	st := w.Status()
+	if st.LastQueued != 2 || st.LastRun.IsZero() || st.Schedule != "every 1h0m0s" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	for _, j := range p.ListJobs(JobFilter{}) {
+		if !j.AutoPublish {
+			t.Fatalf("job not marked for auto publish: %+v", j)
+		}
+	}
+	// Everything is still queued, so a second pass adds nothing.
+	_ = w.Scan()
+	if st := w.Status(); st.LastQueued != 0 || st.LastSkipped != 2 {
+		t.Fatalf("unexpected status after rescan: %+v", st)
+	}
+}
+
+func TestWatchManagerReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watches.json")
+	write := func(body string) {
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewWatchManager(path, NewWorkerPool(1, 1))
+
+	write(`{"watches":[{"name":"a","project":"p","cron":"@daily"},{"name":"b","project":"q","interval":"1h"}]}`)
+	if err := m.Reload(ctx); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	a := m.watchers["a"]
+	if ws := m.Watches(); len(ws) != 2 || ws[0].Name != "a" || ws[1].Name != "b" {
+		t.Fatalf("unexpected watches: %+v", ws)
+	}
+
+	// A broken file keeps the running watchers.
+	write(`{"watches":[`)
+	if err := m.Reload(ctx); err == nil || m.LoadError() == "" || len(m.Watches()) != 2 {
+		t.Fatalf("broken file should be reported and ignored")
+	}
+
+	write(`{"watches":[{"name":"a","project":"p","cron":"@daily"},{"name":"c","project":"r","interval":"2h"}]}`)
+	if err := m.Reload(ctx); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	ws := m.Watches()
+	if len(ws) != 2 || ws[0].Name != "a" || ws[1].Name != "c" || m.LoadError() != "" {
+		t.Fatalf("unexpected watches after edit: %+v", ws)
+	}
+	if m.watchers["a"] != a {
+		t.Fatalf("unchanged watcher was restarted")
+	}
+	if ws[1].NextRun.IsZero() {
+		// Run sets NextRun asynchronously; give it a moment.
+		time.Sleep(50 * time.Millisecond)
+		if m.Watches()[1].NextRun.IsZero() {
+			t.Fatalf("next run not scheduled")
+		}
+	}
+}
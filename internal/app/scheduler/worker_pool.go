@@ -4,50 +4,331 @@ import (
 	"context"
 	"eino-gerrit-review/internal/app/eino/core"
 	"eino-gerrit-review/internal/app/eino/flows"
+	"eino-gerrit-review/internal/app/tools"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
 )
 
 type Task struct {
 	ChangeNum     string
 	Patchset      string
 	EnableContext bool
+	// AutoPublish posts the review to Gerrit as soon as it is stored.
+	AutoPublish bool
 }
 
+var (
+	ErrQueueFull  = errors.New("worker pool queue is full")
+	ErrPoolClosed = errors.New("worker pool is closed")
+	// ErrDuplicateTask means the same change and patchset is already queued or running.
+	ErrDuplicateTask = errors.New("review already queued for this patchset")
+	// ErrStaleTask means a newer patchset of the change is already queued or running.
+	ErrStaleTask = errors.New("newer patchset already queued")
+)
+
+// changeState tracks the jobs of one change so that duplicates collapse,
+// older patchsets are superseded and reviews of a change never overlap.
+type changeState struct {
+	latest  string
+	pending map[string]*Job
+	running *Job
+	// run is held while a job of this change executes.
+	run sync.Mutex
+}
+
+// WorkerPool runs review tasks on a fixed number of workers fed by a bounded queue.
+// It is created once per process and shared by every producer.
 type WorkerPool struct {
-	ch chan Task
+	ch      chan *Job
+	workers int
+
+	mu      sync.Mutex
+	closed  bool
+	changes map[string]*changeState
+	jobs    *jobRegistry
+	dead    *deadLetters
+	retry   RetryPolicy
+	wg      sync.WaitGroup
+	// cancel aborts tasks that are still running when Shutdown gives up waiting.
+	cancel context.CancelFunc
+
+	// exec runs a single task and returns the stored review ID; tests replace it
+	// to avoid the review graph.
+	exec func(ctx context.Context, t Task) (string, error)
 }
 
-func NewWorkerPool(size int) *WorkerPool { return &WorkerPool{ch: make(chan Task, size)} }
+// NewWorkerPool returns a pool with the given number of workers and queue capacity.
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	return &WorkerPool{
+		ch:      make(chan *Job, queueSize),
+		workers: workers,
+		changes: make(map[string]*changeState),
+		jobs:    newJobRegistry(1000),
+		dead:    newDeadLetters(500),
+		retry:   DefaultRetryPolicy(),
+		exec:    runReview,
+	}
+}
 
-func (p *WorkerPool) Submit(t Task) { p.ch <- t }
+// Submit enqueues t without blocking; see SubmitJob.
+func (p *WorkerPool) Submit(t Task) error {
+	_, err := p.SubmitJob(t)
+	return err
+}
 
+// SubmitJob enqueues t without blocking and returns its job. It returns ErrQueueFull
+// when the queue is at capacity and ErrPoolClosed after Shutdown has been called.
+// A task for a patchset that is already pending returns the existing job with
+// ErrDuplicateTask; a newer patchset of the same change cancels the older review.
+func (p *WorkerPool) SubmitJob(t Task) (Job, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return Job{}, ErrPoolClosed
+	}
+	cs := p.changes[t.ChangeNum]
+	if cs != nil {
+		if j := cs.pending[t.Patchset]; j != nil && !j.finished() {
+			return *j, ErrDuplicateTask
+		}
+		if newerPatchset(cs.latest, t.Patchset) {
+			return Job{}, ErrStaleTask
+		}
+	}
+	// Producers only send while holding p.mu, so a free slot seen here stays free.
+	if len(p.ch) == cap(p.ch) {
+		return Job{}, ErrQueueFull
+	}
+	j := p.jobs.add(t)
+	p.ch <- j
+	if cs == nil {
+		cs = &changeState{pending: make(map[string]*Job)}
+		p.changes[t.ChangeNum] = cs
+	}
+	cs.pending[t.Patchset] = j
+	cs.latest = t.Patchset
+	if r := cs.running; r != nil && r.Patchset != t.Patchset && r.cancel != nil {
+		r.cancel()
+	}
+	return *j, nil
+}
+
+// Pending returns the number of queued tasks not yet picked up by a worker.
+func (p *WorkerPool) Pending() int { return len(p.ch) }
+
+// Run starts the workers. Cancelling ctx aborts running tasks immediately;
+// use Shutdown for a graceful stop.
 func (p *WorkerPool) Run(ctx context.Context) {
-	go func() {
-		f := &flows.ReviewFlow{}
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case t := <-p.ch:
-				fc := core.NewFlowContext()
-				fc.ChangeNum = t.ChangeNum
-				fc.Patchset = t.Patchset
-				fc.EnableContext = t.EnableContext
-
-				// Use a derived context or the pool's context if needed,
-				// but here we start a new background context for the task
-				// to ensure it completes even if the pool shuts down immediately (optional design choice),
-				// OR use the pool's context to support cancellation.
-				// Let's use the pool's context to allow graceful shutdown cancellation.
-				res, err := f.Execute(ctx, fc)
-				if err != nil {
-					// In a real app, use a logger
-					// log.Printf("Task failed for %s/%s: %v", t.ChangeNum, t.Patchset, err)
-					continue
-				}
-				if v, ok := res["preview"].(map[string]interface{}); ok {
-					core.PutReview("S-"+t.ChangeNum+"-"+t.Patchset, v, t.ChangeNum, t.Patchset)
-				}
+	taskCtx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for j := range p.ch {
+				p.runJob(taskCtx, j)
 			}
+		}()
+	}
+}
+
+// runJob executes j unless it was cancelled or a newer patchset superseded it,
+// waiting for any other review of the same change to finish first. Transient
+// failures are scheduled again with backoff; the rest end up in the dead-letter list.
+func (p *WorkerPool) runJob(parent context.Context, j *Job) {
+	retrying := false
+	defer func() {
+		if !retrying {
+			p.release(j)
+		}
+	}()
+	t := j.task
+	p.mu.Lock()
+	cs := p.changes[t.ChangeNum]
+	skip := skipReason(parent, cs, j)
+	if skip != "" && !j.finished() {
+		j.finish(JobCancelled, skip)
+	}
+	p.mu.Unlock()
+	if skip != "" {
+		return
+	}
+	cs.run.Lock()
+	defer cs.run.Unlock()
+
+	p.mu.Lock()
+	if skip := skipReason(parent, cs, j); skip != "" {
+		if !j.finished() {
+			j.finish(JobCancelled, skip)
 		}
+		p.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	j.start(cancel)
+	cs.running = j
+	p.mu.Unlock()
+
+	reviewID, err := p.exec(ctx, t)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cs.running = nil
+	switch {
+	case j.finished():
+		// Cancelled through CancelJob while running.
+	case err == nil:
+		j.ReviewID = reviewID
+		j.finish(JobSucceeded, "")
+	case parent.Err() != nil:
+		j.finish(JobCancelled, "worker pool shut down")
+	case ctx.Err() != nil:
+		j.finish(JobCancelled, "superseded by patchset "+cs.latest)
+	default:
+		class := tools.ErrorClass(err)
+		if delay, ok := p.retry.Next(class, j.Attempts); ok && !p.closed {
+			log.Printf("review task %s/%s failed (%s), retry %d in %s: %v", t.ChangeNum, t.Patchset, class, j.Attempts, delay, err)
+			j.State = JobQueued
+			j.Error = err.Error()
+			j.NextAttemptAt = time.Now().Add(delay)
+			j.cancel = nil
+			retrying = true
+			time.AfterFunc(delay, func() { p.requeue(j) })
+			return
+		}
+		log.Printf("review task %s/%s failed after %d attempt(s): %v", t.ChangeNum, t.Patchset, j.Attempts, err)
+		j.finish(JobFailed, err.Error())
+		p.dead.add(*j, class)
+	}
+}
+
+// requeue puts a job waiting for its retry back on the queue.
+func (p *WorkerPool) requeue(j *Job) {
+	p.mu.Lock()
+	switch {
+	case j.finished():
+		// Cancelled during backoff.
+	case p.closed:
+		j.finish(JobCancelled, "worker pool shut down")
+	case len(p.ch) == cap(p.ch):
+		// Queue is full; try again after another base delay.
+		j.NextAttemptAt = time.Now().Add(p.retry.BaseDelay)
+		time.AfterFunc(p.retry.BaseDelay, func() { p.requeue(j) })
+		p.mu.Unlock()
+		return
+	default:
+		p.ch <- j
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	p.release(j)
+}
+
+// skipReason explains why a queued job must not start, or returns "".
+// Callers hold p.mu.
+func skipReason(parent context.Context, cs *changeState, j *Job) string {
+	switch {
+	case j.finished():
+		return j.Error
+	case parent.Err() != nil:
+		return "worker pool shut down"
+	case cs.latest != j.Patchset:
+		return "superseded by patchset " + cs.latest
+	}
+	return ""
+}
+
+func (p *WorkerPool) release(j *Job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cs := p.changes[j.ChangeNum]
+	if cs == nil {
+		return
+	}
+	// A cancelled job may already have been replaced by a resubmission.
+	if cs.pending[j.Patchset] == j {
+		delete(cs.pending, j.Patchset)
+	}
+	if len(cs.pending) == 0 {
+		delete(p.changes, j.ChangeNum)
+	}
+}
+
+// newerPatchset reports whether patchset a is newer than b. Non-numeric
+// patchsets cannot be ordered and are never considered newer.
+func newerPatchset(a, b string) bool {
+	x, err1 := strconv.Atoi(a)
+	y, err2 := strconv.Atoi(b)
+	return err1 == nil && err2 == nil && x > y
+}
+
+// Shutdown stops accepting tasks and waits for queued and in-flight tasks to finish.
+// If ctx expires first the remaining tasks are cancelled and ctx.Err() is returned.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.ch)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
 	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if p.cancel != nil {
+			p.cancel()
+		}
+		return ctx.Err()
+	}
+}
+
+func runReview(ctx context.Context, t Task) (string, error) {
+	fc := core.NewFlowContext()
+	fc.ChangeNum = t.ChangeNum
+	fc.Patchset = t.Patchset
+	fc.EnableContext = t.EnableContext
+	res, err := (&flows.ReviewFlow{}).Execute(ctx, fc)
+	if err != nil {
+		return "", err
+	}
+	// A superseded review must not overwrite anything; its result is stale.
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	v, ok := res["preview"].(map[string]interface{})
+	if !ok {
+		return "", errors.New("review flow returned no preview")
+	}
+	id := "S-" + t.ChangeNum + "-" + t.Patchset
+	model, _ := res["model"].(string)
+	if err := core.PutReview(core.ReviewStored{ID: id, Payload: v, ChangeNum: t.ChangeNum, Patchset: t.Patchset, Model: model}); err != nil {
+		return "", err
+	}
+	if t.AutoPublish {
+		// A failed publish is recorded on the review and can be retried through
+		// POST /reviews/{id}/publish without running the model again.
+		_, err := (&tools.GerritTool{}).PostReview(t.ChangeNum, t.Patchset, v)
+		_ = core.MarkReviewPublished(id, err)
+		if err != nil {
+			log.Printf("review %s: auto publish failed: %v", id, err)
+		}
+	}
+	return id, nil
 }
@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolSubmitQueueFull(t *testing.T) {
+	p := NewWorkerPool(1, 1)
+	if err := p.Submit(Task{ChangeNum: "1"}); err != nil {
+		t.Fatalf("first submit: %v", err)
+	}
+	if err := p.Submit(Task{ChangeNum: "2"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestWorkerPoolShutdownDrains(t *testing.T) {
+	p := NewWorkerPool(3, 10)
+	var done int32
+	p.exec = func(ctx context.Context, t Task) (string, error) {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&done, 1)
+		return "S-" + t.ChangeNum, nil
+	}
+	p.Run(context.Background())
+	for i := 0; i < 6; i++ {
+		if err := p.Submit(Task{ChangeNum: strconv.Itoa(i), Patchset: "1"}); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if n := atomic.LoadInt32(&done); n != 6 {
+		t.Fatalf("expected 6 tasks drained, got %d", n)
+	}
+	if err := p.Submit(Task{}); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+}
+
+func TestWorkerPoolCollapsesDuplicates(t *testing.T) {
+	p := NewWorkerPool(2, 10)
+	if err := p.Submit(Task{ChangeNum: "7", Patchset: "2"}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if err := p.Submit(Task{ChangeNum: "7", Patchset: "2"}); !errors.Is(err, ErrDuplicateTask) {
+		t.Fatalf("expected ErrDuplicateTask, got %v", err)
+	}
+	if err := p.Submit(Task{ChangeNum: "7", Patchset: "1"}); !errors.Is(err, ErrStaleTask) {
+		t.Fatalf("expected ErrStaleTask, got %v", err)
+	}
+}
+
+func TestWorkerPoolNewerPatchsetCancelsOlder(t *testing.T) {
+	p := NewWorkerPool(2, 10)
+	started := make(chan string, 4)
+	var cancelled, completed int32
+	p.exec = func(ctx context.Context, t Task) (string, error) {
+		started <- t.Patchset
+		if t.Patchset == "1" {
+			<-ctx.Done()
+			atomic.AddInt32(&cancelled, 1)
+			return "", ctx.Err()
+		}
+		atomic.AddInt32(&completed, 1)
+		return "S-9-2", nil
+	}
+	p.Run(context.Background())
+	if err := p.Submit(Task{ChangeNum: "9", Patchset: "1"}); err != nil {
+		t.Fatalf("submit ps1: %v", err)
+	}
+	if ps := <-started; ps != "1" {
+		t.Fatalf("expected ps1 to start, got %s", ps)
+	}
+	if err := p.Submit(Task{ChangeNum: "9", Patchset: "2"}); err != nil {
+		t.Fatalf("submit ps2: %v", err)
+	}
+	if ps := <-started; ps != "2" {
+		t.Fatalf("expected ps2 to start, got %s", ps)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if atomic.LoadInt32(&cancelled) != 1 || atomic.LoadInt32(&completed) != 1 {
+		t.Fatalf("cancelled=%d completed=%d", cancelled, completed)
+	}
+}
+
+func TestWorkerPoolJobLifecycle(t *testing.T) {
+	p := NewWorkerPool(1, 10)
+	release := make(chan struct{})
+	p.exec = func(ctx context.Context, t Task) (string, error) {
+		switch t.ChangeNum {
+		case "1":
+			<-release
+			return "S-1-1", nil
+		case "2":
+			return "", errors.New("gerrit 503")
+		}
+		return "", nil
+	}
+	ok, _ := p.SubmitJob(Task{ChangeNum: "1", Patchset: "1"})
+	bad, _ := p.SubmitJob(Task{ChangeNum: "2", Patchset: "1"})
+	queued, _ := p.SubmitJob(Task{ChangeNum: "3", Patchset: "1"})
+	if ok.State != JobQueued {
+		t.Fatalf("new job state = %s", ok.State)
+	}
+	if dup, err := p.SubmitJob(Task{ChangeNum: "1", Patchset: "1"}); !errors.Is(err, ErrDuplicateTask) || dup.ID != ok.ID {
+		t.Fatalf("duplicate should return existing job, got %+v %v", dup, err)
+	}
+	if _, err := p.CancelJob(queued.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	p.Run(context.Background())
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if j, _ := p.GetJob(ok.ID); j.State != JobSucceeded || j.ReviewID != "S-1-1" || j.FinishedAt.IsZero() {
+		t.Fatalf("unexpected ok job: %+v", j)
+	}
+	if j, _ := p.GetJob(bad.ID); j.State != JobFailed || j.Error != "gerrit 503" {
+		t.Fatalf("unexpected failed job: %+v", j)
+	}
+	if j, _ := p.GetJob(queued.ID); j.State != JobCancelled || !j.StartedAt.IsZero() {
+		t.Fatalf("unexpected cancelled job: %+v", j)
+	}
+	if _, err := p.CancelJob(ok.ID); !errors.Is(err, ErrJobFinished) {
+		t.Fatalf("expected ErrJobFinished, got %v", err)
+	}
+	if got := p.ListJobs(JobFilter{State: JobFailed}); len(got) != 1 || got[0].ID != bad.ID {
+		t.Fatalf("unexpected list: %+v", got)
+	}
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/config"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterRule(spinSleepRule{})
+	RegisterRule(uiThreadSleepRule{})
+	RegisterRule(webViewRule{})
+	RegisterRule(fileTooLongRule{})
+	RegisterRule(functionTooLongRule{})
+}
+
+var (
+	spinLockRe   = regexp.MustCompile(`\bspin_lock(_irqsave|_irq|_bh)?\s*\(`)
+	spinUnlockRe = regexp.MustCompile(`\bspin_unlock(_irqrestore|_irq|_bh)?\s*\(`)
+	sleepRe      = regexp.MustCompile(`\b(msleep|msleep_interruptible|ssleep|usleep_range)\s*\(`)
+)
+
+// spinSleepRule flags sleeping calls added while a spin lock is held.
+type spinSleepRule struct{}
+
+func (spinSleepRule) ID() string          { return "linux-spin-sleep" }
+func (spinSleepRule) Languages() []string { return []string{"c", "cpp"} }
+func (spinSleepRule) PathGlobs() []string { return nil }
+func (spinSleepRule) Severity() string    { return "high" }
+
+func (spinSleepRule) Check(f *RuleFile) []Finding {
+	if !f.Config.LinuxSpinSleep {
+		return nil
+	}
+	var out []Finding
+	for _, l := range f.Added {
+		loc := sleepRe.FindStringIndex(l.Text)
+		if loc == nil || lockDepth(f.Above(l.New, loc[0])) == 0 {
+			continue
+		}
+		out = append(out, Finding{
+			Line:    l.New,
+			Title:   "自旋锁内睡眠",
+			Detail:  "spin_lock 区间包含 msleep 可能导致死锁或调度问题",
+			Suggest: "避免在自旋锁持有期间睡眠，改用合适的同步原语或重构逻辑",
+		})
+	}
+	return out
+}
+
+// lockDepth counts the spin locks still held at the end of lines.
+func lockDepth(lines []string) int {
+	depth := 0
+	for _, s := range lines {
+		depth += len(spinLockRe.FindAllStringIndex(s, -1)) - len(spinUnlockRe.FindAllStringIndex(s, -1))
+		if depth < 0 {
+			depth = 0
+		}
+	}
+	return depth
+}
+
+// uiThreadSleepRule flags Thread.sleep added to Android UI classes.
+type uiThreadSleepRule struct{}
+
+func (uiThreadSleepRule) ID() string          { return "android-ui-sleep" }
+func (uiThreadSleepRule) Languages() []string { return []string{"java", "kotlin"} }
+func (uiThreadSleepRule) PathGlobs() []string { return []string{"*Activity*", "*Fragment*"} }
+func (uiThreadSleepRule) Severity() string    { return "high" }
+
+func (uiThreadSleepRule) Check(f *RuleFile) []Finding {
+	if !f.Config.AndroidUiSleep {
+		return nil
+	}
+	var out []Finding
+	for _, l := range f.Added {
+		if strings.Contains(l.Text, "Thread.sleep") {
+			out = append(out, Finding{
+				Line:    l.New,
+				Title:   "主线程阻塞",
+				Detail:  path.Base(f.Path) + " 中调用 Thread.sleep 阻塞 UI 线程",
+				Suggest: "在后台线程执行耗时操作或使用 Handler/Post 延迟",
+			})
+		}
+	}
+	return out
+}
+
+// webViewRule flags new WebView usage in files that never turn JavaScript off.
+type webViewRule struct{}
+
+func (webViewRule) ID() string          { return "android-webview-js" }
+func (webViewRule) Languages() []string { return []string{"java", "kotlin"} }
+func (webViewRule) PathGlobs() []string { return nil }
+func (webViewRule) Severity() string    { return "medium" }
+
+func (webViewRule) Check(f *RuleFile) []Finding {
+	if !f.Config.AndroidWebView || strings.Contains(f.Content, "setJavaScriptEnabled(false)") {
+		return nil
+	}
+	for _, l := range f.Added {
+		if strings.Contains(l.Text, "WebView") {
+			return []Finding{{
+				Line:    l.New,
+				Title:   "WebView 安全设置缺失",
+				Detail:  "未显式关闭或管控 JavaScript，可能存在风险",
+				Suggest: "根据业务需要配置 WebSettings 并限制敏感能力",
+			}}
+		}
+	}
+	return nil
+}
+
+// fileTooLongRule flags changes that add to a file longer than the configured limit.
+type fileTooLongRule struct{}
+
+func (fileTooLongRule) ID() string          { return "file-too-long" }
+func (fileTooLongRule) Languages() []string { return nil }
+func (fileTooLongRule) PathGlobs() []string { return nil }
+func (fileTooLongRule) Severity() string    { return "medium" }
+
+// lengthLimit resolves a line limit: the most specific entry of byPath, else
+// the language's entry of byLang, else def.
+func lengthLimit(byPath, byLang map[string]int, def int, p, lang string) int {
+	if v, ok := config.MostSpecific(byPath, p); ok && v > 0 {
+		return v
+	}
+	if v, ok := byLang[lang]; ok && v > 0 {
+		return v
+	}
+	return def
+}
+
+func (fileTooLongRule) Check(f *RuleFile) []Finding {
+	cfg := f.Config
+	limit := lengthLimit(cfg.PathLengthLimit, cfg.LengthLimitByLang, cfg.FunctionLengthLimit, f.Path, f.Lang)
+	if !cfg.FileTooLong || limit <= 0 || f.Length <= limit || len(f.Added) == 0 {
+		return nil
+	}
+	// Point at the first added line past the limit, or the first added line
+	// when the change grows an already long file elsewhere.
+	line := f.Added[0].New
+	for _, l := range f.Added {
+		if l.New > limit {
+			line = l.New
+			break
+		}
+	}
+	return []Finding{{
+		Line:    line,
+		Title:   "文件过长",
+		Detail:  "文件超过 " + strconv.Itoa(limit) + " 行，建议拆分以提升可维护性",
+		Suggest: "重构为更小的模块或函数",
+	}}
+}
+
+// functionTooLongRule flags functions touched by the change that are longer
+// than the configured limit. Only functions whose every line is known count, so
+// long functions need file context unless the change adds them whole.
+type functionTooLongRule struct{}
+
+func (functionTooLongRule) ID() string          { return "function-too-long" }
+func (functionTooLongRule) Languages() []string { return nil }
+func (functionTooLongRule) PathGlobs() []string { return nil }
+func (functionTooLongRule) Severity() string    { return "medium" }
+
+func (functionTooLongRule) Check(f *RuleFile) []Finding {
+	cfg := f.Config
+	limit := lengthLimit(cfg.PathFunctionLengthLimit, cfg.FunctionLengthLimitByLang, cfg.FunctionLengthLimit, f.Path, f.Lang)
+	if !cfg.FunctionTooLong || limit <= 0 {
+		return nil
+	}
+	var out []Finding
+	for _, fn := range f.Functions() {
+		n := fn.End - fn.Start + 1
+		if n <= limit || !f.Complete(fn.Start, fn.End) {
+			continue
+		}
+		for _, l := range f.Added {
+			if l.New >= fn.Start && l.New <= fn.End {
+				out = append(out, Finding{
+					Line:    l.New,
+					Title:   "函数过长",
+					Detail:  "函数 " + fn.QualifiedName() + " 共 " + strconv.Itoa(n) + " 行，超过 " + strconv.Itoa(limit) + " 行限制",
+					Suggest: "将其拆分为职责单一的小函数",
+				})
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,326 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// Bounds of one reference search: files larger than maxMirrorFile are
+// skipped, at most maxRefFiles files and maxRefBytes bytes are read, and git
+// grep gets refSearchTimeout.
+const (
+	maxMirrorFile    = 1 << 20
+	maxRefFiles      = 64
+	maxRefBytes      = 8 << 20
+	refSearchTimeout = 10 * time.Second
+)
+
+// referenceSource supplies files beyond the change in which to look for
+// callers and callees. It may return files that turn out not to reference any
+// of the names.
+type referenceSource interface {
+	// Files calls fn with the slash-separated path and content of each source
+	// file that mentions one of names.
+	Files(names []string, fn func(path, content string)) error
+	// Name is reported as ContextInfo.Source.
+	Name() string
+}
+
+// referenceSourceFor returns the working tree under CONTEXT_MIRROR_DIR for
+// project, or nil when no mirror is configured. A <dir>/<project> checkout is
+// preferred, so one directory can hold several projects.
+func referenceSourceFor(project string) referenceSource {
+	root := os.Getenv("CONTEXT_MIRROR_DIR")
+	if root == "" {
+		return nil
+	}
+	if project != "" {
+		sub := filepath.Join(root, filepath.FromSlash(project))
+		if st, err := os.Stat(sub); err == nil && st.IsDir() {
+			root = sub
+		}
+	}
+	return dirSource{root: root}
+}
+
+// dirSource searches a checked-out working tree, which need not be a git
+// repository, with git grep --no-index.
+type dirSource struct {
+	root string
+}
+
+func (s dirSource) Name() string { return "mirror" }
+
+func (s dirSource) Files(names []string, fn func(path, content string)) error {
+	run := func(ctx context.Context, args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.root}, args...)...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return string(out), nil
+	}
+	read := func(p string) (string, error) {
+		b, err := os.ReadFile(filepath.Join(s.root, filepath.FromSlash(p)))
+		return string(b), err
+	}
+	return grepRefs(run, "", names, read, fn)
+}
+
+// grepRefs lists the files mentioning one of names as a whole word with git
+// grep, in the tree of rev or, without one, in the working directory, and
+// calls fn with each source file read by read, within the bounds above.
+func grepRefs(git func(ctx context.Context, args ...string) (string, error), rev string, names []string, read func(p string) (string, error), fn func(path, content string)) error {
+	if len(names) == 0 {
+		return nil
+	}
+	args := []string{"grep"}
+	if rev == "" {
+		args = append(args, "--no-index", "--exclude-standard")
+	}
+	args = append(args, "-l", "-I", "-F", "-w")
+	for _, n := range names {
+		args = append(args, "-e", n)
+	}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), refSearchTimeout)
+	defer cancel()
+	out, err := git(ctx, append(args, "--")...)
+	if err != nil {
+		// git grep exits 1 when nothing matches.
+		if strings.Contains(err.Error(), "exit status 1") {
+			return nil
+		}
+		return err
+	}
+	files, size := 0, 0
+	for _, l := range strings.Split(strings.TrimSpace(out), "\n") {
+		p := strings.TrimPrefix(l, rev+":")
+		if _, ok := familyFor(p); !ok || p == "" {
+			continue
+		}
+		if files >= maxRefFiles {
+			break
+		}
+		s, err := read(p)
+		if err != nil || len(s) > maxMirrorFile || size+len(s) > maxRefBytes {
+			continue
+		}
+		files, size = files+1, size+len(s)
+		fn(p, s)
+	}
+	return nil
+}
+
+// parsedFile is a source file tokenized once for the call search.
+type parsedFile struct {
+	path   string
+	lines  []string
+	toks   []token
+	funcs  []Symbol
+	source string
+}
+
+func parseFile(path, content, source string) (*parsedFile, bool) {
+	fam, ok := familyFor(path)
+	if !ok {
+		return nil, false
+	}
+	return &parsedFile{
+		path:   path,
+		lines:  strings.Split(content, "\n"),
+		toks:   tokenize(content, fam),
+		funcs:  Functions(outline(content, fam)),
+		source: source,
+	}, true
+}
+
+// calls yields the name and line of every call-shaped "name(" token in f
+// between lines from and to.
+func (f *parsedFile) calls(from, to int, yield func(name string, line int)) {
+	for i := 0; i+1 < len(f.toks); i++ {
+		t := f.toks[i]
+		if t.line < from || t.line > to || f.toks[i+1].text != "(" || !isName(t.text) || notFunctions[t.text] {
+			continue
+		}
+		yield(t.text, t.line)
+	}
+}
+
+// enclosingFunc returns the innermost function of f spanning line.
+func (f *parsedFile) enclosingFunc(line int) (Symbol, bool) {
+	var best Symbol
+	ok := false
+	for _, s := range f.funcs {
+		if line >= s.Start && line <= s.End {
+			best, ok = s, true
+		}
+	}
+	return best, ok
+}
+
+// snippet returns lines from..to of f as context of kind for symbol.
+func (f *parsedFile) snippet(kind, symbol string, from, to int) ContextInfo {
+	if from < 1 {
+		from = 1
+	}
+	if to > len(f.lines) {
+		to = len(f.lines)
+	}
+	return ContextInfo{
+		FilePath:    f.path,
+		ContextType: kind,
+		Symbol:      symbol,
+		Content:     strings.Join(f.lines[from-1:to], "\n"),
+		StartLine:   from,
+		EndLine:     to,
+		Source:      f.source,
+	}
+}
+
+// callContexts returns the callers of the functions the diffs change and the
+// definitions those functions call, as "callers" and "callees" context of at
+// most budget bytes in total. files holds the new content of the changed
+// files; src, when set, is searched as well. Call sites are matched by name,
+// ignoring comments and literals, so overloads and same-named methods of
+// other classes are included.
+func callContexts(diffs []FileDiff, files map[string]sourceFile, src referenceSource, budget int) []ContextInfo {
+	if budget <= 0 {
+		return nil
+	}
+	parsed := make(map[string]*parsedFile)
+	changed := make(map[string][]Symbol) // path -> changed functions
+	targets := make(map[string]bool)     // changed function names
+	callees := make(map[string]bool)     // names the changed functions call
+	for _, fd := range diffs {
+		sf, ok := files[fd.Path]
+		if !ok {
+			continue
+		}
+		f, ok := parseFile(fd.Path, sf.content, sf.source)
+		if !ok {
+			continue
+		}
+		parsed[fd.Path] = f
+		for _, r := range changedRanges(fd) {
+			for _, s := range f.funcs {
+				if s.Start <= r.End && r.Start <= s.End && !containsSymbol(changed[fd.Path], s) {
+					changed[fd.Path] = append(changed[fd.Path], s)
+				}
+			}
+		}
+		for _, s := range changed[fd.Path] {
+			if len(s.Name) >= 3 {
+				targets[s.Name] = true
+			}
+			f.calls(s.Start, s.End, func(name string, _ int) {
+				if name != s.Name && len(name) >= 3 {
+					callees[name] = true
+				}
+			})
+		}
+	}
+	if len(targets) == 0 && len(callees) == 0 {
+		return nil
+	}
+	if src != nil {
+		// Only callers are searched for: callees such as printk appear in
+		// nearly every file, so their definitions come from the change.
+		err := src.Files(sortedSet(targets), func(path, content string) {
+			if _, ok := parsed[path]; ok {
+				// The change's own version wins over the mirror's.
+				return
+			}
+			if f, ok := parseFile(path, content, src.Name()); ok {
+				parsed[path] = f
+			}
+		})
+		if err != nil {
+			g.Log().Warningf(context.Background(), "searching %s for callers failed: %v", src.Name(), err)
+		}
+	}
+
+	paths := make([]string, 0, len(parsed))
+	for p := range parsed {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	surround := atoi(getenv("CONTEXT_SURROUND_LINES", "5"))
+	// No single snippet may take more than a quarter of the budget; longer
+	// functions shrink to the lines around the call or header.
+	maxSnippet := budget / 4
+	fit := func(f *parsedFile, kind, symbol string, s Symbol, from, to int) ContextInfo {
+		c := f.snippet(kind, symbol, s.Start, s.End)
+		if len(c.Content) > maxSnippet {
+			c = f.snippet(kind, symbol, max(from, s.Start), min(to, s.End))
+		}
+		return c
+	}
+
+	var callers, defs []ContextInfo
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		f := parsed[p]
+		f.calls(1, len(f.lines), func(name string, line int) {
+			if !targets[name] {
+				return
+			}
+			s, ok := f.enclosingFunc(line)
+			// Skip declarations, definitions, recursion and code under review.
+			if !ok || s.Name == name || containsSymbol(changed[p], s) {
+				return
+			}
+			key := p + ":" + s.QualifiedName() + ":" + name
+			if !seen[key] {
+				seen[key] = true
+				callers = append(callers, fit(f, "callers", name, s, line-surround, line+surround))
+			}
+		})
+		for _, s := range f.funcs {
+			if callees[s.Name] && !containsSymbol(changed[p], s) {
+				defs = append(defs, fit(f, "callees", s.Name, s, s.Start, s.Start+2*surround))
+			}
+		}
+	}
+
+	var out []ContextInfo
+	for _, c := range append(callers, defs...) {
+		if len(c.Content) > budget {
+			continue
+		}
+		budget -= len(c.Content)
+		out = append(out, c)
+	}
+	return out
+}
+
+func containsSymbol(ss []Symbol, s Symbol) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedSet(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
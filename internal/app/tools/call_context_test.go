@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/testutil"
+	"testing"
+)
+
+func TestCallContexts(t *testing.T) {
+	changedSrc := "static int helper(int x) { return x * 2; }\n\nint compute(int x)\n{\n\treturn helper(x) + 1;\n}\n"
+	fd := FileDiff{Path: "lib/compute.c", Hunks: []Hunk{{OldStart: 5, NewStart: 5, Lines: []Line{{Kind: LineAdded, New: 5}}}}}
+
+	mirror := t.TempDir()
+	testutil.WriteFiles(t, mirror, map[string]string{
+		"app/main.c":    "int compute(int x);\n\n/* compute(0) is never called here */\nint main(void)\n{\n\tputs(\"compute(1)\");\n\treturn compute(2);\n}\n",
+		"lib/compute.c": "int compute(int x) { return 0; }\n",
+		".git/x.c":      "void hidden(void) { compute(3); }\n",
+		"README.md":     "compute(4)\n",
+		// Only callers are searched for, so a file mentioning just a callee
+		// or a longer name is not read.
+		"lib/util.c":      "int helper(int x) { return x; }\n",
+		"lib/recompute.c": "void recompute(void) { }\n",
+	})
+
+	got := callContexts([]FileDiff{fd}, map[string]sourceFile{fd.Path: {changedSrc, "gerrit"}}, dirSource{root: mirror}, 4096)
+	if len(got) != 2 {
+		t.Fatalf("got %d items: %+v", len(got), got)
+	}
+	c := got[0]
+	if c.ContextType != "callers" || c.FilePath != "app/main.c" || c.Symbol != "compute" || c.StartLine != 4 || c.EndLine != 8 || c.Source != "mirror" {
+		t.Fatalf("caller: %+v", c)
+	}
+	d := got[1]
+	if d.ContextType != "callees" || d.FilePath != "lib/compute.c" || d.Symbol != "helper" || d.StartLine != 1 || d.Source != "gerrit" {
+		t.Fatalf("callee: %+v", d)
+	}
+
+	// Long functions shrink to the lines around the call, and items that no
+	// longer fit the budget are dropped.
+	t.Setenv("CONTEXT_SURROUND_LINES", "1")
+	got = callContexts([]FileDiff{fd}, map[string]sourceFile{fd.Path: {changedSrc, "gerrit"}}, dirSource{root: mirror}, 50)
+	if len(got) != 1 || got[0].ContextType != "callers" || got[0].StartLine != 6 || got[0].EndLine != 8 {
+		t.Fatalf("budget: %+v", got)
+	}
+}
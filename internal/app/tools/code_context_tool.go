@@ -1,13 +1,16 @@
 package tools
 
 import (
+	"context"
 	"eino-gerrit-review/internal/app/policies"
 	"eino-gerrit-review/internal/monitor"
+	"errors"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
-	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
 )
 
 type ContextInfo struct {
@@ -17,11 +20,24 @@ type ContextInfo struct {
 	StartLine   int
 	EndLine     int
 	Source      string
+	// For "blame" items StartLine and EndLine are base-revision lines.
+	// Symbol is the changed function a "callers" item calls, the function a
+	// "callees" item defines, or the function a "compare" item pairs.
+	Symbol string
+	// OldContent is the base version of a "compare" item, at OldStartLine to
+	// OldEndLine of the parent revision.
+	OldContent   string
+	OldStartLine int
+	OldEndLine   int
 }
 
-type CodeContextTool struct{}
+// CodeContextTool fetches context for a change. Project selects the git
+// mirror files are read from, and the mirror or CONTEXT_MIRROR_DIR checkout
+// searched for callers and callees.
+type CodeContextTool struct {
+	Project string
+}
 
-var ctxCache sync.Map
 var ctxLimiter = policies.NewRateLimiter(10)
 
 func (t *CodeContextTool) Fetch(enable bool, changeNum, patchset string, diffs []map[string]interface{}) []ContextInfo {
@@ -30,15 +46,28 @@ func (t *CodeContextTool) Fetch(enable bool, changeNum, patchset string, diffs [
 	}
 
 	type result struct {
-		info ContextInfo
-		err  error
+		ctxs []ContextInfo
+		fd   FileDiff
+		file sourceFile
 	}
-
 	results := make(chan result, len(diffs))
 	var wg sync.WaitGroup
 
-	g := &GerritTool{}
+	gt := &GerritTool{}
 	filter := &FileFilter{}
+	var mirror ContentSource
+	var blamer *GitMirror
+	refs := referenceSourceFor(t.Project)
+	rev := cacheRevision(changeNum, patchset)
+	if m := gitMirrorFor(t.Project); m != nil {
+		if commit, err := m.Resolve(changeNum, patchset); err != nil {
+			g.Log().Warningf(context.Background(), "git mirror unavailable for %s, reading from Gerrit: %v", t.Project, err)
+		} else {
+			mirror, blamer, refs, rev = m, m, m.references(commit), commit
+		}
+	}
+	blameLimit := atoi(getenv("CONTEXT_BLAME_LINES", "20"))
+	cache := contextCache()
 
 	for _, d := range diffs {
 		wg.Add(1)
@@ -52,73 +81,61 @@ func (t *CodeContextTool) Fetch(enable bool, changeNum, patchset string, diffs [
 				return
 			}
 
-			key := p + "@rev"
 			monitor.IncContextCall()
-
-			if v, ok := ctxCache.Load(key); ok {
-				item := v.(struct {
-					exp time.Time
-					val string
-				})
-				if time.Now().Before(item.exp) {
-					monitor.IncContextHit()
-					results <- result{info: ContextInfo{
-						FilePath:    p,
-						ContextType: granularity(),
-						Content:     item.val,
-						StartLine:   1,
-						EndLine:     len(strings.Split(item.val, "\n")),
-						Source:      "gerrit",
-					}}
-					return
+			// load reads p, or the base version of the file, through the cache.
+			// Failed reads are not cached, so the next review tries again.
+			load := func(path string, parent bool) (sourceFile, error) {
+				key := ctxKey{Project: t.Project, Revision: rev, Path: path}
+				if parent {
+					key.Revision += "^1"
 				}
-			}
-
-			ctxLimiter.Acquire()
-			// Fetch original file content from parent revision (base), not the modified version
-			content, _ := g.GetFileContent(changeNum, patchset, p)
-
-			// size limit in KB
-			limitKB := atoi(getenv("CONTEXT_FILE_LIMIT", "10"))
-			if limitKB > 0 {
-				max := limitKB * 1024
-				if len(content) > max {
-					content = content[:max]
+				if rev != "" {
+					if f, ok := cache.Get(key); ok {
+						monitor.IncContextHit()
+						return f, nil
+					}
 				}
+				f, err := readFile(mirror, gt, changeNum, patchset, path, parent)
+				monitor.IncContextMiss()
+				if err != nil {
+					return f, err
+				}
+				if rev != "" {
+					cache.Add(key, f)
+				}
+				return f, nil
 			}
 
-			monitor.IncContextMiss()
-			ctxCache.Store(key, struct {
-				exp time.Time
-				val string
-			}{exp: time.Now().Add(300 * time.Second), val: content})
-
+			// The revision's own content, so context lines match the new side of the diff.
+			f, err := load(p, false)
+			if err != nil {
+				g.Log().Warningf(context.Background(), "change %s: reading %s for context failed: %v", changeNum, p, err)
+				return
+			}
+			fd := diffFile(d)
 			gr := granularity()
-			ad := adapterForPath(p)
-			var finalContent string
-
-			switch gr {
-			case "dependency":
-				monitor.IncContextDep()
-				finalContent = ad.ExtractDependencies(content)
-			case "function":
-				monitor.IncContextFunc()
-				finalContent = ad.ExtractFunction(content)
-			case "class":
-				monitor.IncContextClass()
-				finalContent = ad.ExtractClass(content)
-			default:
-				finalContent = content
+			var base string
+			if gr == "compare" && (fd.Change == ChangeModified || fd.Change == ChangeRenamed) {
+				old := fd.OldPath
+				if old == "" {
+					old = p
+				}
+				if b, err := load(old, true); err != nil {
+					g.Log().Warningf(context.Background(), "change %s: reading base of %s for context failed: %v", changeNum, old, err)
+				} else {
+					base = b.content
+				}
 			}
-
-			results <- result{info: ContextInfo{
-				FilePath:    p,
-				ContextType: gr,
-				Content:     finalContent,
-				StartLine:   1,
-				EndLine:     len(strings.Split(finalContent, "\n")),
-				Source:      "gerrit",
-			}}
+			ctxs := fileContext(fd, f.content, base, gr)
+			for i := range ctxs {
+				ctxs[i].Source = f.source
+			}
+			if blamer != nil && blameLimit > 0 && (fd.Change == ChangeModified || fd.Change == ChangeRenamed) {
+				blame := blameContexts(blamer, rev, fd, blameLimit)
+				monitor.AddContextBlame(len(blame))
+				ctxs = append(ctxs, blame...)
+			}
+			results <- result{ctxs: ctxs, fd: fd, file: f}
 		}(d)
 	}
 
@@ -128,18 +145,108 @@ func (t *CodeContextTool) Fetch(enable bool, changeNum, patchset string, diffs [
 	}()
 
 	res := make([]ContextInfo, 0, len(diffs))
+	var changed []FileDiff
+	files := make(map[string]sourceFile)
 	for r := range results {
-		if r.info.FilePath != "" {
-			res = append(res, r.info)
+		res = append(res, r.ctxs...)
+		if r.file.content != "" {
+			changed = append(changed, r.fd)
+			files[r.fd.Path] = r.file
+		}
+	}
+	calls := callContexts(changed, files, refs, atoi(getenv("CONTEXT_CALLERS_LIMIT", "4"))*1024)
+	monitor.AddContextCallers(len(calls))
+	return append(res, calls...)
+}
+
+// sourceFile is file content and the name of the source that served it.
+type sourceFile struct {
+	content string
+	source  string
+}
+
+// readFile reads p from mirror when there is one, from the patch set's parent
+// when parent is set. Gerrit, rate limited, is the fallback for every mirror
+// failure except a file missing from the revision. A missing file reads as
+// empty; other Gerrit failures are returned.
+func readFile(mirror, gerrit ContentSource, changeNum, patchset, p string, parent bool) (sourceFile, error) {
+	read := func(src ContentSource) (string, error) {
+		if parent {
+			return src.GetFileContentFromParent(changeNum, patchset, p)
+		}
+		return src.GetFileContent(changeNum, patchset, p)
+	}
+	if mirror != nil {
+		s, err := read(mirror)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			return sourceFile{s, "mirror"}, nil
+		}
+		g.Log().Warningf(context.Background(), "git mirror read of %s failed, reading from Gerrit: %v", p, err)
+	}
+	ctxLimiter.Acquire()
+	s, err := read(gerrit)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return sourceFile{}, err
+	}
+	return sourceFile{s, "gerrit"}, nil
+}
+
+// fileContext builds the context of granularity gr for one file. Function and
+// class context is anchored on the changed hunks; see anchoredContexts.
+// Compare context pairs each changed function with its version in base, the
+// parent's content, and falls back to function context without one.
+func fileContext(fd FileDiff, content, base, gr string) []ContextInfo {
+	ad := adapterForPath(fd.Path)
+	var finalContent string
+
+	switch gr {
+	case "dependency":
+		monitor.IncContextDep()
+		finalContent = ad.ExtractDependencies(limitSize(content))
+	case "function":
+		monitor.IncContextFunc()
+		if out := anchoredContexts(fd, content, gr, SymbolFunction); out != nil {
+			return out
 		}
+		finalContent = ad.ExtractFunction(content)
+	case "compare":
+		monitor.IncContextCompare()
+		if base != "" {
+			if out := comparedContexts(fd, base, content); out != nil {
+				return out
+			}
+		}
+		if out := anchoredContexts(fd, content, "function", SymbolFunction); out != nil {
+			return out
+		}
+		finalContent = ad.ExtractFunction(content)
+	case "class":
+		monitor.IncContextClass()
+		if out := anchoredContexts(fd, content, gr, SymbolClass); out != nil {
+			return out
+		}
+		finalContent = ad.ExtractClass(content)
+	default:
+		finalContent = limitSize(content)
 	}
-	return res
+	if gr == "compare" {
+		gr = "function"
+	}
+
+	return []ContextInfo{{
+		FilePath:    fd.Path,
+		ContextType: gr,
+		Content:     finalContent,
+		StartLine:   1,
+		EndLine:     len(strings.Split(finalContent, "\n")),
+		Source:      "gerrit",
+	}}
 }
 
 func granularity() string {
 	g := os.Getenv("CONTEXT_GRANULARITY")
 	switch g {
-	case "function", "class", "file", "dependency":
+	case "function", "class", "file", "dependency", "compare":
 		return g
 	default:
 		return "file"
@@ -177,75 +284,6 @@ func extractDependencies(s string) string {
 	return out.String()
 }
 
-func extractFirstFunction(s string) string {
-	lines := strings.Split(s, "\n")
-	var buf strings.Builder
-	depth := 0
-	started := false
-
-	for _, l := range lines {
-		if !started {
-			if (strings.Contains(l, "(") && strings.Contains(l, ")") && strings.Contains(l, "{")) ||
-				(strings.Contains(l, "(") && strings.Contains(l, ")") && !strings.Contains(l, ";") && strings.HasSuffix(strings.TrimSpace(l), "{")) {
-				started = true
-				depth = 1
-				buf.WriteString(l)
-				buf.WriteByte('\n')
-				continue
-			}
-			continue
-		}
-
-		buf.WriteString(l)
-		buf.WriteByte('\n')
-		depth += strings.Count(l, "{")
-		depth -= strings.Count(l, "}")
-		if depth <= 0 {
-			break
-		}
-	}
-
-	res := buf.String()
-	if res == "" {
-		return limitSize(s)
-	}
-	return res
-}
-
-func extractFirstClass(s string) string {
-	lines := strings.Split(s, "\n")
-	var buf strings.Builder
-	depth := 0
-	started := false
-
-	for _, l := range lines {
-		if !started {
-			if strings.HasPrefix(l, "class ") || strings.Contains(l, " class ") {
-				started = true
-				depth = 1
-				buf.WriteString(l)
-				buf.WriteByte('\n')
-				continue
-			}
-			continue
-		}
-
-		buf.WriteString(l)
-		buf.WriteByte('\n')
-		depth += strings.Count(l, "{")
-		depth -= strings.Count(l, "}")
-		if depth <= 0 {
-			break
-		}
-	}
-
-	res := buf.String()
-	if res == "" {
-		return limitSize(s)
-	}
-	return res
-}
-
 func limitSize(s string) string {
 	limit := atoi(getenv("CONTEXT_FILE_LIMIT", "10")) * 1024
 	if limit <= 0 {
@@ -1,6 +1,14 @@
 package tools
 
-import "testing"
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
 
 func TestFetchContextBasic(t *testing.T) {
 	diffs := []map[string]interface{}{{"path": "kernel/lock.c", "patch": ""}}
@@ -12,3 +20,122 @@ func TestFetchContextBasic(t *testing.T) {
 		t.Fatalf("wrong file")
 	}
 }
+
+func TestFetchDoesNotCacheFailedReads(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(base64.StdEncoding.EncodeToString([]byte("int x;\n"))))
+	}))
+	defer srv.Close()
+	t.Setenv("GERRIT_BASE_URL", srv.URL)
+	t.Setenv("CONTEXT_GRANULARITY", "file")
+	defer FlushContextCache("cache/retry")
+
+	diffs := []map[string]interface{}{{"path": "a.c", "patch": "+ [L1] int x;"}}
+	tool := &CodeContextTool{Project: "cache/retry"}
+	if out := tool.Fetch(true, "31", "2", diffs); len(out) != 0 {
+		t.Fatalf("failed read produced context: %+v", out)
+	}
+	fail.Store(false)
+	out := tool.Fetch(true, "31", "2", diffs)
+	if len(out) != 1 || out[0].Content != "int x;\n" {
+		t.Fatalf("got %+v", out)
+	}
+	if n := FlushContextCache("cache/retry"); n != 1 {
+		t.Fatalf("flushed %d entries", n)
+	}
+}
+
+func TestChangedRanges(t *testing.T) {
+	fd := FileDiff{Hunks: []Hunk{
+		{OldStart: 3, NewStart: 3, Lines: []Line{
+			{Kind: LineContext, Old: 3, New: 3}, {Kind: LineAdded, New: 4}, {Kind: LineAdded, New: 5},
+			{Kind: LineDeleted, Old: 4}, {Kind: LineContext, Old: 5, New: 6},
+		}},
+		{OldStart: 20, NewStart: 21, Lines: []Line{
+			{Kind: LineContext, Old: 20, New: 21}, {Kind: LineDeleted, Old: 21},
+		}},
+	}}
+	got := changedRanges(fd)
+	want := []lineRange{{4, 6}, {21, 21}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAnchoredContexts(t *testing.T) {
+	t.Setenv("CONTEXT_SURROUND_LINES", "1")
+	src := strings.Join([]string{
+		"class A {",                 // 1
+		"    void a() {",            // 2
+		"        int x = 1;",        // 3
+		"        int y = 2;",        // 4
+		"    }",                     // 5
+		"",                          // 6
+		"    void b() {",            // 7
+		"        String s = \"}\";", // 8
+		"    }",                     // 9
+		"",                          // 10
+		"    void c() {",            // 11
+		"    }",                     // 12
+		"}",                         // 13
+	}, "\n")
+	hunk := func(n int) Hunk {
+		return Hunk{OldStart: n, NewStart: n, Lines: []Line{{Kind: LineAdded, New: n}}}
+	}
+	// Two changes in a() merge; the change in b() stays anchored on b().
+	fd := FileDiff{Path: "A.java", Hunks: []Hunk{hunk(3), hunk(4), hunk(8)}}
+	got := anchoredContexts(fd, src, "function", SymbolFunction)
+	if len(got) != 1 || got[0].StartLine != 1 || got[0].EndLine != 10 {
+		t.Fatalf("adjacent functions should merge with surround lines, got %+v", got)
+	}
+	t.Setenv("CONTEXT_SURROUND_LINES", "0")
+	got = anchoredContexts(fd, src, "function", SymbolFunction)
+	if len(got) != 2 || got[0].StartLine != 2 || got[0].EndLine != 5 || got[1].StartLine != 7 || got[1].EndLine != 9 {
+		t.Fatalf("got %+v", got)
+	}
+	if want := "    void b() {\n        String s = \"}\";\n    }"; got[1].Content != want {
+		t.Fatalf("content %q, want %q", got[1].Content, want)
+	}
+	got = anchoredContexts(fd, src, "class", SymbolClass)
+	if len(got) != 1 || got[0].StartLine != 1 || got[0].EndLine != 13 {
+		t.Fatalf("class context should cover the class, got %+v", got)
+	}
+	// Without an enclosing symbol only the changed lines and surroundings remain.
+	got = anchoredContexts(FileDiff{Path: "a.py", Hunks: []Hunk{hunk(8)}}, src, "function", SymbolFunction)
+	if len(got) != 1 || got[0].StartLine != 8 || got[0].EndLine != 8 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestContextCacheKeys(t *testing.T) {
+	if got := cacheRevision("123", "2"); got != "123/2" {
+		t.Fatalf("numbered patch set: %q", got)
+	}
+	if got := cacheRevision("123", "current"); got != "" {
+		t.Fatalf("moving revisions must not be cached: %q", got)
+	}
+
+	c := contextCache()
+	c.Add(ctxKey{"p1", "1/1", "a.c"}, sourceFile{"one", "gerrit"})
+	c.Add(ctxKey{"p1", "2/1", "a.c"}, sourceFile{"two", "gerrit"})
+	c.Add(ctxKey{"p2", "3/1", "a.c"}, sourceFile{"three", "gerrit"})
+	if f, ok := c.Get(ctxKey{"p1", "2/1", "a.c"}); !ok || f.content != "two" {
+		t.Fatalf("same path in another change: %+v, %v", f, ok)
+	}
+	if n := FlushContextCache("p1"); n != 2 {
+		t.Fatalf("flushed %d", n)
+	}
+	if _, ok := c.Get(ctxKey{"p2", "3/1", "a.c"}); !ok {
+		t.Fatalf("other projects must survive a project flush")
+	}
+	FlushContextCache("")
+	if s := ContextCacheStats(); s.Size != 0 {
+		t.Fatalf("stats after flush %+v", s)
+	}
+}
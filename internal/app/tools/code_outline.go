@@ -0,0 +1,459 @@
+package tools
+
+import "strings"
+
+// langFamily selects the lexical rules tokenize applies.
+type langFamily int
+
+const (
+	familyC langFamily = iota // C and C++
+	familyJava
+	familyKotlin
+)
+
+// SymbolKind tells classes from functions in an outline.
+type SymbolKind string
+
+const (
+	SymbolClass    SymbolKind = "class"
+	SymbolFunction SymbolKind = "function"
+)
+
+// Symbol is a class or function with a body. Start is the 1-based line of its
+// header and End that of its closing brace. Class is the innermost enclosing
+// class, or the qualifier of an out-of-line C++ method.
+type Symbol struct {
+	Kind  SymbolKind
+	Name  string
+	Class string
+	Start int
+	End   int
+}
+
+// QualifiedName returns "Class.Name", or Name outside a class.
+func (s Symbol) QualifiedName() string {
+	if s.Class == "" {
+		return s.Name
+	}
+	return s.Class + "." + s.Name
+}
+
+// token is an identifier or punctuation. Literals become a single `"` token so
+// headers such as @Named("x") keep their shape.
+type token struct {
+	text string
+	line int
+	// first is set on the first token of a source line.
+	first bool
+}
+
+// tokenize splits src into tokens, dropping whitespace, comments, string and
+// character literals, numbers and, for C, preprocessor lines.
+func tokenize(src string, fam langFamily) []token {
+	var out []token
+	line, first := 1, true
+	emit := func(s string) {
+		out = append(out, token{text: s, line: line, first: first})
+		first = false
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			first = true
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			i, line = skipBlockComment(src, i, line, fam == familyKotlin)
+		case c == '#' && first && fam == familyC:
+			// Preprocessor line, with backslash continuations.
+			for i < len(src) && src[i] != '\n' {
+				if src[i] == '\\' && i+1 < len(src) && src[i+1] == '\n' {
+					line++
+					i++
+				}
+				i++
+			}
+		case c == '"':
+			start := line
+			if fam == familyC && len(out) > 0 && strings.HasSuffix(out[len(out)-1].text, "R") && i > 0 && src[i-1] == 'R' {
+				// C++ raw string: the prefix was lexed as an identifier.
+				out = out[:len(out)-1]
+				i, line = skipRawString(src, i, line)
+			} else {
+				i, line = skipString(src, i, line, fam)
+			}
+			out = append(out, token{text: `"`, line: start, first: first})
+			first = false
+		case c == '\'':
+			i, line = skipQuoted(src, i, line, '\'')
+			emit(`"`)
+		case c >= '0' && c <= '9':
+			// Numbers, including 0x1F, 1e-3f and C++14 1'000.
+			for i < len(src) && (isIdent(src[i]) || src[i] == '.' || src[i] == '\'' ||
+				((src[i] == '-' || src[i] == '+') && (src[i-1] == 'e' || src[i-1] == 'E' || src[i-1] == 'p' || src[i-1] == 'P'))) {
+				i++
+			}
+		case isIdent(c):
+			j := i
+			for j < len(src) && isIdent(src[j]) {
+				j++
+			}
+			emit(src[i:j])
+			i = j
+		case strings.HasPrefix(src[i:], "::") || strings.HasPrefix(src[i:], "->"):
+			emit(src[i : i+2])
+			i += 2
+		default:
+			emit(string(c))
+			i++
+		}
+	}
+	return out
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// skipBlockComment returns the offset after the comment opening at i. Kotlin
+// block comments nest.
+func skipBlockComment(src string, i, line int, nested bool) (int, int) {
+	depth := 0
+	for i < len(src) {
+		switch {
+		case strings.HasPrefix(src[i:], "/*") && (nested || depth == 0):
+			depth++
+			i += 2
+		case strings.HasPrefix(src[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i, line
+			}
+		default:
+			if src[i] == '\n' {
+				line++
+			}
+			i++
+		}
+	}
+	return i, line
+}
+
+// skipQuoted skips a literal closed by q on the same line, honouring escapes.
+func skipQuoted(src string, i, line int, q byte) (int, int) {
+	for i++; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case q:
+			return i + 1, line
+		case '\n':
+			// Unterminated: resume on the next line.
+			return i, line
+		}
+	}
+	return i, line
+}
+
+// skipString skips a string literal starting at the quote at i: Java and
+// Kotlin """ blocks, and Kotlin ${...} templates, which may hold braces and
+// strings of their own.
+func skipString(src string, i, line int, fam langFamily) (int, int) {
+	if fam != familyC && strings.HasPrefix(src[i:], `"""`) {
+		end := strings.Index(src[i+3:], `"""`)
+		if end < 0 {
+			return len(src), line + strings.Count(src[i:], "\n")
+		}
+		end += i + 3
+		// A closing """ may be followed by more quotes that belong to the text.
+		for end+3 < len(src) && src[end+3] == '"' {
+			end++
+		}
+		return end + 3, line + strings.Count(src[i:end], "\n")
+	}
+	if fam != familyKotlin {
+		return skipQuoted(src, i, line, '"')
+	}
+	for i++; i < len(src); i++ {
+		switch {
+		case src[i] == '\\':
+			i++
+		case src[i] == '"':
+			return i + 1, line
+		case src[i] == '\n':
+			return i, line
+		case strings.HasPrefix(src[i:], "${"):
+			depth := 0
+			for i += 2; i < len(src); i++ {
+				if src[i] == '"' {
+					i, line = skipString(src, i, line, fam)
+					i--
+					continue
+				}
+				if src[i] == '{' {
+					depth++
+				} else if src[i] == '}' {
+					if depth == 0 {
+						break
+					}
+					depth--
+				} else if src[i] == '\n' {
+					line++
+				}
+			}
+		}
+	}
+	return i, line
+}
+
+// skipRawString skips a C++ R"delim(...)delim" literal whose quote is at i.
+func skipRawString(src string, i, line int) (int, int) {
+	open := strings.IndexByte(src[i:], '(')
+	if open < 0 {
+		return skipQuoted(src, i, line, '"')
+	}
+	closing := ")" + src[i+1:i+open] + `"`
+	end := strings.Index(src[i+open:], closing)
+	if end < 0 {
+		return len(src), line + strings.Count(src[i:], "\n")
+	}
+	end += i + open + len(closing)
+	return end, line + strings.Count(src[i:end], "\n")
+}
+
+// notFunctions are keywords that look like calls in a block header.
+var notFunctions = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "synchronized": true,
+	"return": true, "new": true, "else": true, "do": true, "try": true, "when": true, "sizeof": true,
+	"foreach": true, "using": true, "throw": true, "case": true,
+}
+
+var accessLabels = map[string]bool{"public": true, "protected": true, "private": true}
+
+var classKeywords = map[string]bool{"class": true, "interface": true, "enum": true, "struct": true, "record": true, "object": true}
+
+// outline lists the classes and functions in src in order of their headers.
+func outline(src string, fam langFamily) []Symbol {
+	toks := tokenize(src, fam)
+	type frame struct {
+		sym   int // index into out, or -1 for plain blocks
+		kind  SymbolKind
+		class string // innermost enclosing class name
+		paren int    // paren depth to restore on "}"
+		anon  bool   // anonymous class body
+	}
+	var (
+		out   []Symbol
+		stack []frame
+		hs    int // first token of the current header
+		paren int
+	)
+	for i, t := range toks {
+		// Kotlin statements end at line breaks outside parentheses.
+		if fam == familyKotlin && t.first && paren == 0 && i > hs {
+			hs = i
+		}
+		switch t.text {
+		case "(", "[":
+			paren++
+		case ")", "]":
+			if paren > 0 {
+				paren--
+			}
+		case ";":
+			if paren == 0 {
+				hs = i + 1
+			}
+		case ":":
+			// C++ access labels end a statement too.
+			if fam == familyC && paren == 0 && i == hs+1 && accessLabels[toks[hs].text] {
+				hs = i + 1
+			}
+		case "{":
+			parent := frame{sym: -1}
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			f := frame{sym: -1, class: parent.class, paren: paren}
+			if paren == 0 {
+				header := toks[hs:i]
+				inClass := parent.kind == SymbolClass || parent.anon || len(stack) == 0
+				if s, ok := classifyHeader(header, fam, inClass, parent.kind == SymbolFunction); ok {
+					if s.Class == "" {
+						s.Class = parent.class
+					}
+					s.Start, s.End = header[0].line, t.line
+					out = append(out, s)
+					f.sym, f.kind = len(out)-1, s.Kind
+					if s.Kind == SymbolClass {
+						f.class = s.Name
+					}
+				} else if (len(header) > 0 && header[0].text == "new") || hasToken(header, "object") {
+					f.anon = true
+				}
+			}
+			stack = append(stack, f)
+			paren, hs = 0, i+1
+		case "}":
+			if n := len(stack); n > 0 {
+				f := stack[n-1]
+				stack = stack[:n-1]
+				if f.sym >= 0 {
+					out[f.sym].End = t.line
+				}
+				paren = f.paren
+			}
+			hs = i + 1
+		}
+	}
+	// Close what an unbalanced file leaves open at its last line.
+	if len(toks) > 0 {
+		for _, f := range stack {
+			if f.sym >= 0 {
+				out[f.sym].End = toks[len(toks)-1].line
+			}
+		}
+	}
+	return out
+}
+
+func hasToken(ts []token, s string) bool {
+	for _, t := range ts {
+		if t.text == s {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyHeader decides whether the tokens before a "{" declare a class or a
+// function. inClass allows constructors, which have no return type; inFunc
+// rejects C and Java "functions" nested in a body, which are macros or calls.
+func classifyHeader(h []token, fam langFamily, inClass, inFunc bool) (Symbol, bool) {
+	if len(h) == 0 {
+		return Symbol{}, false
+	}
+	// Only look at the top level of the header.
+	depth := 0
+	top := make([]int, 0, len(h))
+	for i, t := range h {
+		switch t.text {
+		case "(", "[", "<":
+			if t.text != "<" || fam != familyC {
+				depth++
+			}
+			if depth == 1 && t.text != "<" {
+				top = append(top, i)
+			}
+			continue
+		case ")", "]", ">":
+			if (t.text != ">" || fam != familyC) && depth > 0 {
+				depth--
+			}
+			continue
+		}
+		if depth == 0 {
+			top = append(top, i)
+		}
+	}
+	for _, i := range top {
+		if h[i].text == "=" {
+			// Initializers, assignments and expression-bodied Kotlin functions.
+			return Symbol{}, false
+		}
+	}
+	// Classes: the last class keyword followed by a name.
+	for k := len(top) - 1; k >= 0; k-- {
+		i := top[k]
+		if !classKeywords[h[i].text] || (i > 0 && h[i-1].text == ".") {
+			continue
+		}
+		if i+1 < len(h) && isName(h[i+1].text) && !classKeywords[h[i+1].text] {
+			return Symbol{Kind: SymbolClass, Name: h[i+1].text}, true
+		}
+		if h[i].text == "object" && i > 0 && h[i-1].text == "companion" {
+			return Symbol{Kind: SymbolClass, Name: "Companion"}, true
+		}
+		if fam == familyKotlin || fam == familyC {
+			// Anonymous objects and unnamed C structs are plain blocks.
+			return Symbol{}, false
+		}
+	}
+	if fam == familyKotlin {
+		for _, i := range top {
+			if h[i].text != "fun" && h[i].text != "constructor" {
+				continue
+			}
+			if h[i].text == "constructor" {
+				return Symbol{Kind: SymbolFunction, Name: "constructor"}, true
+			}
+			// fun <T> Receiver.name(
+			for j := i + 1; j < len(h); j++ {
+				if h[j].text == "(" {
+					if isName(h[j-1].text) && j-1 > i {
+						return Symbol{Kind: SymbolFunction, Name: h[j-1].text}, true
+					}
+					break
+				}
+			}
+		}
+		return Symbol{}, false
+	}
+	if inFunc {
+		return Symbol{}, false
+	}
+	// C and Java: the name is the identifier before the first top-level "(".
+	open := -1
+	for _, i := range top {
+		if h[i].text == "(" {
+			open = i
+			break
+		}
+	}
+	if open < 1 || !isName(h[open-1].text) || notFunctions[h[open-1].text] {
+		return Symbol{}, false
+	}
+	start := open - 1
+	s := Symbol{Kind: SymbolFunction, Name: h[start].text}
+	if start > 0 && h[start-1].text == "~" {
+		s.Name = "~" + s.Name
+		start--
+	}
+	var qual []string
+	for start >= 2 && h[start-1].text == "::" && isName(h[start-2].text) {
+		qual = append([]string{h[start-2].text}, qual...)
+		start -= 2
+	}
+	s.Class = strings.Join(qual, ".")
+	for _, t := range h[:start] {
+		if notFunctions[t.text] || t.text == "." || t.text == "->" {
+			return Symbol{}, false
+		}
+	}
+	if start == 0 && !inClass {
+		// A bare call such as a C loop macro.
+		return Symbol{}, false
+	}
+	return s, true
+}
+
+func isName(s string) bool {
+	return s != "" && isIdent(s[0]) && (s[0] < '0' || s[0] > '9')
+}
+
+// symbolText returns the lines of src that s spans.
+func symbolText(src string, s Symbol) string {
+	lines := strings.Split(src, "\n")
+	if s.Start < 1 || s.End > len(lines) || s.Start > s.End {
+		return ""
+	}
+	return strings.Join(lines[s.Start-1:s.End], "\n")
+}
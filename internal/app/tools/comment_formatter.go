@@ -1,6 +1,12 @@
 package tools
 
 func FormatForGerrit(advs []map[string]interface{}) map[string]interface{} {
+	return FormatReview(advs, 0)
+}
+
+// FormatReview is FormatForGerrit with the number of findings silenced by
+// ai-review:ignore markers reported in the summary.
+func FormatReview(advs []map[string]interface{}, suppressed int) map[string]interface{} {
 	comments := make(map[string][]map[string]interface{})
 	for _, a := range advs {
 		path, _ := a["file"].(string)
@@ -11,10 +17,16 @@ func FormatForGerrit(advs []map[string]interface{}) map[string]interface{} {
 			"line":    a["line"],
 			"message": a["message"],
 		}
+		if path == PatchsetLevel {
+			delete(c, "line")
+		}
 		comments[path] = append(comments[path], c)
 	}
 	msg := "生成" + itoa(len(advs)) + "条建议"
-	return map[string]interface{}{"message": msg, "comments": comments}
+	if suppressed > 0 {
+		msg += "，" + itoa(suppressed) + "条已被 ai-review:ignore 抑制"
+	}
+	return map[string]interface{}{"message": msg, "comments": comments, "suppressed": suppressed}
 }
 
 func itoa(n int) string {
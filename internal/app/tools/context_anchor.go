@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"sort"
+	"strings"
+)
+
+// lineRange is an inclusive range of 1-based new-file lines.
+type lineRange struct {
+	Start, End int
+}
+
+// changedRanges returns the new-file lines the hunks of fd add, as ordered
+// runs. A deletion has no new line of its own and anchors on the new line
+// after it, or the one before it at the end of a hunk.
+func changedRanges(fd FileDiff) []lineRange {
+	var out []lineRange
+	add := func(n int) {
+		if n <= 0 {
+			return
+		}
+		if k := len(out) - 1; k >= 0 && n <= out[k].End+1 {
+			if n > out[k].End {
+				out[k].End = n
+			}
+			return
+		}
+		out = append(out, lineRange{n, n})
+	}
+	for _, h := range fd.Hunks {
+		last, pending := h.NewStart-1, false
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case LineDeleted:
+				pending = true
+				continue
+			case LineAdded:
+				add(l.New)
+			case LineContext:
+				if pending {
+					add(l.New)
+				}
+			}
+			last, pending = l.New, false
+		}
+		if pending {
+			add(last)
+		}
+	}
+	return out
+}
+
+// anchorRanges widens each changed range to the innermost symbols of kind
+// enclosing its first and last line, then by surround lines on each side,
+// clamps the result to the n lines of the file and merges overlaps.
+func anchorRanges(syms []Symbol, kind SymbolKind, changed []lineRange, surround, n int) []lineRange {
+	enclosing := func(line int) (lineRange, bool) {
+		var best lineRange
+		ok := false
+		for _, s := range syms {
+			if s.Kind != kind || line < s.Start || line > s.End {
+				continue
+			}
+			// Outlines list outer symbols first, so a later hit is nested deeper.
+			best, ok = lineRange{s.Start, s.End}, true
+		}
+		return best, ok
+	}
+	var out []lineRange
+	for _, r := range changed {
+		if s, ok := enclosing(r.Start); ok && s.Start < r.Start {
+			r.Start = s.Start
+		}
+		if s, ok := enclosing(r.End); ok && s.End > r.End {
+			r.End = s.End
+		}
+		r.Start -= surround
+		r.End += surround
+		if r.Start < 1 {
+			r.Start = 1
+		}
+		if r.End > n {
+			r.End = n
+		}
+		if r.Start <= r.End {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	merged := out[:0]
+	for _, r := range out {
+		if k := len(merged) - 1; k >= 0 && r.Start <= merged[k].End+1 {
+			if r.End > merged[k].End {
+				merged[k].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// anchoredContexts returns one ContextInfo per merged range of content around
+// the changes in fd, with StartLine and EndLine set to real file lines. It
+// returns nil when the diff adds no new-file lines to anchor on.
+func anchoredContexts(fd FileDiff, content, gr string, kind SymbolKind) []ContextInfo {
+	changed := changedRanges(fd)
+	if len(changed) == 0 {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+	syms := adapterForPath(fd.Path).Outline(content)
+	surround := atoi(getenv("CONTEXT_SURROUND_LINES", "5"))
+	if surround < 0 {
+		surround = 0
+	}
+	var out []ContextInfo
+	for _, r := range anchorRanges(syms, kind, changed, surround, len(lines)) {
+		text := limitSize(strings.Join(lines[r.Start-1:r.End], "\n"))
+		out = append(out, ContextInfo{
+			FilePath:    fd.Path,
+			ContextType: gr,
+			Content:     text,
+			StartLine:   r.Start,
+			EndLine:     r.Start + strings.Count(text, "\n"),
+			Source:      "gerrit",
+		})
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/app/policies"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ctxKey identifies a file at one revision. Revision is the commit SHA when
+// the git mirror resolved it, else "<change>/<patchset>".
+type ctxKey struct {
+	Project  string
+	Revision string
+	Path     string
+}
+
+var (
+	ctxCacheOnce sync.Once
+	ctxCache     *policies.LRU[ctxKey, sourceFile]
+)
+
+// contextCache returns the file cache shared by all context fetches, holding
+// at most CONTEXT_CACHE_SIZE files and CONTEXT_CACHE_BYTES bytes of content
+// with CONTEXT_CACHE_TTL_SECONDS expiry.
+func contextCache() *policies.LRU[ctxKey, sourceFile] {
+	ctxCacheOnce.Do(func() {
+		size := atoi(getenv("CONTEXT_CACHE_SIZE", "512"))
+		maxBytes := atoi(getenv("CONTEXT_CACHE_BYTES", "67108864"))
+		ttl := time.Duration(atoi(getenv("CONTEXT_CACHE_TTL_SECONDS", "300"))) * time.Second
+		ctxCache = policies.NewSizedLRU[ctxKey, sourceFile](size, maxBytes, ttl, func(f sourceFile) int { return len(f.content) })
+	})
+	return ctxCache
+}
+
+// cacheRevision returns the cache revision of a patch set, or "" when
+// revision names something that moves, such as "current".
+func cacheRevision(changeNum, revision string) string {
+	if shaRe.MatchString(revision) {
+		return revision
+	}
+	if n, err := strconv.Atoi(revision); err == nil && n > 0 && changeNum != "" {
+		return changeNum + "/" + revision
+	}
+	return ""
+}
+
+// ContextCacheStats returns the counters of the context file cache.
+func ContextCacheStats() policies.CacheStats {
+	return contextCache().Stats()
+}
+
+// FlushContextCache drops the cached files of project, or of every project
+// when it is empty, and returns how many were dropped.
+func FlushContextCache(project string) int {
+	return contextCache().RemoveIf(func(k ctxKey) bool {
+		return project == "" || k.Project == project
+	})
+}
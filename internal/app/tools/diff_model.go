@@ -0,0 +1,218 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChangeType describes what happened to a file in a revision.
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeDeleted  ChangeType = "deleted"
+	ChangeModified ChangeType = "modified"
+	ChangeRenamed  ChangeType = "renamed"
+	ChangeCopied   ChangeType = "copied"
+)
+
+// LineKind tells on which side of the diff a line exists.
+type LineKind string
+
+const (
+	LineContext LineKind = "context"
+	LineAdded   LineKind = "added"
+	LineDeleted LineKind = "deleted"
+)
+
+// Line is one diff line. Old is 0 for added lines and New is 0 for deleted ones.
+type Line struct {
+	Kind LineKind `json:"kind"`
+	Old  int      `json:"old,omitempty"`
+	New  int      `json:"new,omitempty"`
+	Text string   `json:"text"`
+}
+
+// Hunk is a contiguous run of lines; Gerrit's skipped regions separate hunks.
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	NewStart int    `json:"newStart"`
+	Lines    []Line `json:"lines"`
+}
+
+// FileDiff is the diff of a single file between the base and a revision.
+type FileDiff struct {
+	Path    string     `json:"path"`
+	OldPath string     `json:"oldPath,omitempty"`
+	Lang    string     `json:"lang"`
+	Change  ChangeType `json:"change"`
+	Binary  bool       `json:"binary"`
+	Hunks   []Hunk     `json:"hunks"`
+}
+
+// gerritDiffInfo is the subset of Gerrit's DiffInfo we read.
+type gerritDiffInfo struct {
+	ChangeType string `json:"change_type"`
+	Binary     bool   `json:"binary"`
+	MetaA      *struct {
+		Name string `json:"name"`
+	} `json:"meta_a"`
+	Content []struct {
+		A    []string `json:"a"`
+		B    []string `json:"b"`
+		AB   []string `json:"ab"`
+		Skip int      `json:"skip"`
+	} `json:"content"`
+}
+
+// ParseGerritDiff builds a FileDiff from the body of Gerrit's
+// /files/{path}/diff endpoint (XSSI prefix already stripped).
+func ParseGerritDiff(path string, body []byte) (FileDiff, error) {
+	var info gerritDiffInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return FileDiff{}, err
+	}
+	fd := FileDiff{Path: path, Lang: detectLang(path), Change: gerritChangeType(info.ChangeType), Binary: info.Binary}
+	if info.MetaA != nil && info.MetaA.Name != path && (fd.Change == ChangeRenamed || fd.Change == ChangeCopied) {
+		fd.OldPath = info.MetaA.Name
+	}
+	oldNum, newNum := 0, 0
+	var cur *Hunk
+	hunk := func() *Hunk {
+		if cur == nil {
+			fd.Hunks = append(fd.Hunks, Hunk{OldStart: oldNum + 1, NewStart: newNum + 1})
+			cur = &fd.Hunks[len(fd.Hunks)-1]
+		}
+		return cur
+	}
+	for _, seg := range info.Content {
+		if seg.Skip > 0 {
+			oldNum += seg.Skip
+			newNum += seg.Skip
+			cur = nil
+			continue
+		}
+		for _, s := range seg.A {
+			h := hunk()
+			oldNum++
+			h.Lines = append(h.Lines, Line{Kind: LineDeleted, Old: oldNum, Text: s})
+		}
+		for _, s := range seg.B {
+			h := hunk()
+			newNum++
+			h.Lines = append(h.Lines, Line{Kind: LineAdded, New: newNum, Text: s})
+		}
+		for _, s := range seg.AB {
+			h := hunk()
+			oldNum++
+			newNum++
+			h.Lines = append(h.Lines, Line{Kind: LineContext, Old: oldNum, New: newNum, Text: s})
+		}
+	}
+	return fd, nil
+}
+
+// gerritChangeType maps both DiffInfo.change_type ("RENAMED") and
+// FileInfo.status ("R") to a ChangeType.
+func gerritChangeType(s string) ChangeType {
+	switch strings.ToUpper(s) {
+	case "ADDED", "A":
+		return ChangeAdded
+	case "DELETED", "D":
+		return ChangeDeleted
+	case "RENAMED", "R":
+		return ChangeRenamed
+	case "COPIED", "C":
+		return ChangeCopied
+	}
+	return ChangeModified
+}
+
+// AddedLines returns every added line in order.
+func (f FileDiff) AddedLines() []Line {
+	var out []Line
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind == LineAdded {
+				out = append(out, l)
+			}
+		}
+	}
+	return out
+}
+
+// Patch renders the diff in the prompt format: "- " for deleted lines,
+// "+ [Lnn] " for added lines and "  [Lnn] " for context, numbered on the new side.
+func (f FileDiff) Patch() string {
+	var b strings.Builder
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case LineDeleted:
+				b.WriteString("- " + l.Text + "\n")
+			case LineAdded:
+				fmt.Fprintf(&b, "+ [L%d] %s\n", l.New, l.Text)
+			default:
+				fmt.Fprintf(&b, "  [L%d] %s\n", l.New, l.Text)
+			}
+		}
+	}
+	return b.String()
+}
+
+// ToMap converts f to the map form passed between review nodes. The typed
+// diff travels under "diff" next to the rendered "patch".
+func (f FileDiff) ToMap() map[string]interface{} {
+	return map[string]interface{}{"path": f.Path, "lang": f.Lang, "patch": f.Patch(), "diff": f}
+}
+
+// DiffOf returns the typed diff carried in a diff map, if any.
+func DiffOf(d map[string]interface{}) (FileDiff, bool) {
+	fd, ok := d["diff"].(FileDiff)
+	return fd, ok
+}
+
+// ParsePatch reads text in the Patch format back into a single-hunk FileDiff, for
+// diffs that were built without the typed model. Lines without a marker are
+// treated as added lines following the previous one.
+func ParsePatch(path, patch string) FileDiff {
+	fd := FileDiff{Path: path, Lang: detectLang(path), Change: ChangeModified}
+	h := Hunk{OldStart: 1, NewStart: 1}
+	newNum := 0
+	for _, s := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		if s == "" && patch == "" {
+			break
+		}
+		l := Line{Kind: LineAdded, Text: s}
+		switch {
+		case strings.HasPrefix(s, "- "):
+			l = Line{Kind: LineDeleted, Text: s[2:]}
+		case strings.HasPrefix(s, "+ [L") || strings.HasPrefix(s, "  [L"):
+			if end := strings.Index(s, "] "); end > 4 {
+				if n, err := strconv.Atoi(s[4:end]); err == nil {
+					l = Line{Kind: LineContext, New: n, Text: s[end+2:]}
+					if s[0] == '+' {
+						l.Kind = LineAdded
+					}
+				}
+			}
+		}
+		if l.Kind != LineDeleted {
+			if l.New == 0 {
+				l.New = newNum + 1
+			}
+			newNum = l.New
+		}
+		h.Lines = append(h.Lines, l)
+	}
+	if len(h.Lines) > 0 {
+		h.NewStart = h.Lines[0].New
+		if h.NewStart == 0 {
+			h.NewStart = 1
+		}
+		fd.Hunks = []Hunk{h}
+	}
+	return fd
+}
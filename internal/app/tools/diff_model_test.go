@@ -0,0 +1,60 @@
+package tools
+
+import "testing"
+
+const renamedDiff = `{
+  "meta_a": {"name": "src/old.c"},
+  "meta_b": {"name": "src/new.c"},
+  "change_type": "RENAMED",
+  "content": [
+    {"ab": ["int a;"]},
+    {"a": ["int b;"], "b": ["int b = 0;", "int c;"]},
+    {"skip": 10},
+    {"ab": ["return;"]},
+    {"b": ["}"]}
+  ]
+}`
+
+func TestParseGerritDiff(t *testing.T) {
+	fd, err := ParseGerritDiff("src/new.c", []byte(renamedDiff))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if fd.Change != ChangeRenamed || fd.OldPath != "src/old.c" || fd.Lang != "c" || fd.Binary {
+		t.Fatalf("unexpected file: %+v", fd)
+	}
+	if len(fd.Hunks) != 2 || fd.Hunks[1].OldStart != 13 || fd.Hunks[1].NewStart != 14 {
+		t.Fatalf("unexpected hunks: %+v", fd.Hunks)
+	}
+	del := fd.Hunks[0].Lines[1]
+	if del.Kind != LineDeleted || del.Old != 2 || del.New != 0 {
+		t.Fatalf("unexpected deleted line: %+v", del)
+	}
+	added := fd.AddedLines()
+	if len(added) != 3 || added[0].New != 2 || added[1].New != 3 || added[2].New != 15 || added[2].Text != "}" {
+		t.Fatalf("unexpected added lines: %+v", added)
+	}
+
+	// The rendering must match the prompt text the LLM has always seen.
+	want := "  [L1] int a;\n" +
+		"- int b;\n" +
+		"+ [L2] int b = 0;\n" +
+		"+ [L3] int c;\n" +
+		"  [L14] return;\n" +
+		"+ [L15] }\n"
+	if got := fd.Patch(); got != want {
+		t.Fatalf("patch mismatch:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDiffToolSkipsBinaryDiffs(t *testing.T) {
+	bin := FileDiff{Path: "assets/blob", Binary: true, Change: ChangeAdded}
+	src := mockFileDiff("code.c", "x;")
+	out := (&DiffTool{}).Parse([]map[string]interface{}{bin.ToMap(), src.ToMap()})
+	if len(out) != 1 || out[0]["path"] != "code.c" {
+		t.Fatalf("unexpected diffs: %+v", out)
+	}
+	if fd, ok := DiffOf(out[0]); !ok || len(fd.AddedLines()) != 1 {
+		t.Fatalf("typed diff lost in DiffTool.Parse")
+	}
+}
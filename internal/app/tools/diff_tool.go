@@ -16,13 +16,21 @@ func (t *DiffTool) Parse(diffs []map[string]interface{}) []map[string]interface{
 		if filter.ShouldSkipFile(p) {
 			continue
 		}
+		fd, typed := DiffOf(d)
+		if typed && fd.Binary {
+			continue
+		}
 		lang := detectLang(p)
 		patch := d["patch"].(string)
 		limit := policies.Default().DiffChunkLines
 		if strings.Count(patch, "\n") > limit {
 			patch = truncateLines(patch, limit)
 		}
-		out = append(out, map[string]interface{}{"path": p, "lang": lang, "patch": patch})
+		m := map[string]interface{}{"path": p, "lang": lang, "patch": patch}
+		if typed {
+			m["diff"] = fd
+		}
+		out = append(out, m)
 	}
 	return out
 }
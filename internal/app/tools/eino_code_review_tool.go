@@ -33,13 +33,22 @@ func NewCodeReviewTool() tool.BaseTool {
 		func(ctx context.Context, in *reviewReq) (out *reviewResp, err error) {
 			gt := &GerritTool{}
 			diffs, _ := gt.GetDiffs(in.ChangeNum, in.Patchset)
-			parsed := (&DiffTool{}).Parse(diffs)
-			ctxs := (&CodeContextTool{}).Fetch(in.EnableContext, in.ChangeNum, in.Patchset, parsed)
-			static := (&StaticRuleTool{}).Run(parsed, ctxs)
-			prompt := BuildPrompt(joinPatches(parsed), ctxs)
+			project, branch := gt.ChangeTarget(in.ChangeNum, "", "")
+			prof, notices, err := gt.ReviewProfile(in.ChangeNum, in.Patchset, project, branch)
+			if err != nil {
+				return nil, err
+			}
+			parsed := DropIgnored((&DiffTool{}).Parse(diffs), prof)
+			ctxs := (&CodeContextTool{Project: project}).Fetch(in.EnableContext, in.ChangeNum, in.Patchset, parsed)
+			st := &StaticRuleTool{Profile: &prof}
+			static := st.Run(parsed, ctxs)
+			prompt := WithGuidelines(BuildPrompt(joinPatches(parsed), ctxs), prof.PromptExtra)
 			llm, _ := (&LLMTool{}).Generate(prompt)
+			llm, suppressed := FilterSuppressedLLM(llm, parsed, ctxs)
+			static, llm = FilterSeverity(static, llm, prof)
+			static = append(notices, static...)
 			merged := Synthesize(static, llm)
-			payload := FormatForGerrit(merged)
+			payload := FormatReview(merged, st.Suppressed+suppressed)
 			return &reviewResp{Preview: payload}, nil
 		},
 	)
@@ -1,11 +1,60 @@
 package tools
 
-import "errors"
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+)
 
 var (
     ErrGerritAuth    = errors.New("gerrit auth error")
     ErrRateLimited   = errors.New("rate limited")
     ErrModelTimeout  = errors.New("model timeout")
     ErrInvalidDiff   = errors.New("invalid diff")
+    ErrGerritUnavailable = errors.New("gerrit unavailable")
+    ErrNetwork       = errors.New("network error")
+    ErrNotFound      = errors.New("not found")
+)
+
+// Error classes returned by ErrorClass. Only these are worth retrying.
+const (
+    ClassRateLimited       = "rate_limited"
+    ClassModelTimeout      = "model_timeout"
+    ClassGerritUnavailable = "gerrit_unavailable"
+    ClassNetwork           = "network"
 )
 
+// ErrorClass returns the retry class of err, or "" when retrying cannot help.
+func ErrorClass(err error) string {
+    var ne net.Error
+    switch {
+    case err == nil, errors.Is(err, context.Canceled):
+        return ""
+    case errors.Is(err, ErrRateLimited):
+        return ClassRateLimited
+    case errors.Is(err, ErrModelTimeout):
+        return ClassModelTimeout
+    case errors.Is(err, ErrGerritUnavailable):
+        return ClassGerritUnavailable
+    case errors.Is(err, ErrNetwork), errors.As(err, &ne):
+        return ClassNetwork
+    }
+    return ""
+}
+
+// statusError maps a failed Gerrit response onto the sentinel errors above.
+func statusError(resp *http.Response, msg string) error {
+    switch {
+    case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+        return fmt.Errorf("%s: %w", msg, ErrGerritAuth)
+    case resp.StatusCode == http.StatusTooManyRequests:
+        return fmt.Errorf("%s: %w", msg, ErrRateLimited)
+    case resp.StatusCode >= 500:
+        return fmt.Errorf("%s: %w", msg, ErrGerritUnavailable)
+    case resp.StatusCode == http.StatusNotFound:
+        return fmt.Errorf("%s: %w", msg, ErrNotFound)
+    }
+    return fmt.Errorf("%s: %s", msg, resp.Status)
+}
@@ -0,0 +1,130 @@
+package tools
+
+import "sort"
+
+// comparedContexts pairs the base and patched versions of every function the
+// diff of fd touches, as "compare" context. Content, StartLine and EndLine are
+// the patched version; OldContent, OldStartLine and OldEndLine the base one.
+// Added and deleted functions leave the missing side empty. It returns nil
+// when the language has no outline or no function was touched.
+func comparedContexts(fd FileDiff, oldSrc, newSrc string) []ContextInfo {
+	fam, ok := familyFor(fd.Path)
+	if !ok {
+		return nil
+	}
+	oldFns, newFns := Functions(outline(oldSrc, fam)), Functions(outline(newSrc, fam))
+	changedOld := touchedFunctions(oldFns, editedRanges(fd, LineDeleted))
+	changedNew := touchedFunctions(newFns, editedRanges(fd, LineAdded))
+
+	var out []ContextInfo
+	usedOld, usedNew := make(map[Symbol]bool), make(map[Symbol]bool)
+	emit := func(o, n *Symbol) {
+		c := ContextInfo{FilePath: fd.Path, ContextType: "compare"}
+		if n != nil {
+			usedNew[*n] = true
+			c.Symbol = n.QualifiedName()
+			c.Content, c.StartLine, c.EndLine = limitSize(symbolText(newSrc, *n)), n.Start, n.End
+		}
+		if o != nil {
+			usedOld[*o] = true
+			c.Symbol = o.QualifiedName()
+			c.OldContent, c.OldStartLine, c.OldEndLine = limitSize(symbolText(oldSrc, *o)), o.Start, o.End
+		}
+		out = append(out, c)
+	}
+	for _, n := range changedNew {
+		n := n
+		o, ok := counterpart(n, oldFns, changedOld, usedOld)
+		if ok {
+			emit(&o, &n)
+		} else {
+			emit(nil, &n)
+		}
+	}
+	for _, o := range changedOld {
+		if usedOld[o] {
+			continue
+		}
+		o := o
+		n, ok := counterpart(o, newFns, nil, usedNew)
+		if ok {
+			emit(&o, &n)
+		} else {
+			emit(&o, nil)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return pairLine(out[i]) < pairLine(out[j]) })
+	return out
+}
+
+// editedRanges returns the runs of lines of kind, deleted lines on the old
+// side or added lines on the new one. Unlike changedRanges, a pure deletion
+// touches nothing on the new side, so unchanged neighbours are not paired.
+func editedRanges(fd FileDiff, kind LineKind) []lineRange {
+	var out []lineRange
+	for _, h := range fd.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind != kind {
+				continue
+			}
+			n := l.New
+			if kind == LineDeleted {
+				n = l.Old
+			}
+			if k := len(out) - 1; k >= 0 && n == out[k].End+1 {
+				out[k].End = n
+			} else {
+				out = append(out, lineRange{n, n})
+			}
+		}
+	}
+	return out
+}
+
+// touchedFunctions returns the functions of fns overlapping any of rs.
+func touchedFunctions(fns []Symbol, rs []lineRange) []Symbol {
+	var out []Symbol
+	for _, s := range fns {
+		for _, r := range rs {
+			if s.Start <= r.End && r.Start <= s.End {
+				out = append(out, s)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// counterpart finds s on the other side of the diff by qualified name. Of
+// several overloads it prefers one in prefer, then the one nearest s.
+func counterpart(s Symbol, pool, prefer []Symbol, used map[Symbol]bool) (Symbol, bool) {
+	var best Symbol
+	bestScore := -1
+	for _, c := range pool {
+		if used[c] || c.QualifiedName() != s.QualifiedName() {
+			continue
+		}
+		dist := c.Start - s.Start
+		if dist < 0 {
+			dist = -dist
+		}
+		score := 1 << 30
+		if containsSymbol(prefer, c) {
+			score *= 2
+		}
+		score -= dist
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// pairLine orders compare context by the patched line, or the base line of a
+// deleted function.
+func pairLine(c ContextInfo) int {
+	if c.StartLine > 0 {
+		return c.StartLine
+	}
+	return c.OldStartLine
+}
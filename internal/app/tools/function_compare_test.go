@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComparedContexts(t *testing.T) {
+	oldSrc := "class A {\n    void check(String s) {\n        if (s == null) return;\n        use(s);\n    }\n    void gone() {\n    }\n    void same() {\n    }\n}\n"
+	newSrc := "class A {\n    void check(String s) {\n        use(s);\n    }\n    void same() {\n    }\n    void added() {\n    }\n}\n"
+	ctx := func(o, n int) Line { return Line{Kind: LineContext, Old: o, New: n} }
+	fd := FileDiff{Path: "A.java", Change: ChangeModified, Hunks: []Hunk{{OldStart: 1, NewStart: 1, Lines: []Line{
+		ctx(1, 1), ctx(2, 2),
+		{Kind: LineDeleted, Old: 3},
+		ctx(4, 3), ctx(5, 4),
+		{Kind: LineDeleted, Old: 6}, {Kind: LineDeleted, Old: 7},
+		ctx(8, 5), ctx(9, 6),
+		{Kind: LineAdded, New: 7}, {Kind: LineAdded, New: 8},
+		ctx(10, 9),
+	}}}}
+	got := comparedContexts(fd, oldSrc, newSrc)
+
+	type pair struct {
+		sym              string
+		start, end       int
+		oldStart, oldEnd int
+		hasNew, hasOld   bool
+	}
+	var gotPairs []pair
+	for _, c := range got {
+		if c.ContextType != "compare" || c.FilePath != "A.java" {
+			t.Fatalf("unexpected item %+v", c)
+		}
+		gotPairs = append(gotPairs, pair{c.Symbol, c.StartLine, c.EndLine, c.OldStartLine, c.OldEndLine, c.Content != "", c.OldContent != ""})
+	}
+	want := []pair{
+		{"A.check", 2, 4, 2, 5, true, true},
+		{"A.gone", 0, 0, 6, 7, false, true},
+		{"A.added", 7, 8, 0, 0, true, false},
+	}
+	if len(gotPairs) != len(want) {
+		t.Fatalf("got %+v, want %+v", gotPairs, want)
+	}
+	for i := range want {
+		if gotPairs[i] != want[i] {
+			t.Fatalf("pair %d: got %+v, want %+v", i, gotPairs[i], want[i])
+		}
+	}
+	if !strings.Contains(got[0].OldContent, "s == null") || strings.Contains(got[0].Content, "s == null") {
+		t.Fatalf("check versions: %q / %q", got[0].OldContent, got[0].Content)
+	}
+
+	p := BuildPrompt("", got[:2])
+	for _, s := range []string{"函数 A.check 变更前后对比", "[变更前 第 2-5 行]", "[变更后 第 2-4 行]", "[变更后] 无（函数已删除）"} {
+		if !strings.Contains(p, s) {
+			t.Fatalf("prompt lacks %q:\n%s", s, p)
+		}
+	}
+}
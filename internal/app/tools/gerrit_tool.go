@@ -4,7 +4,6 @@ import (
 	"eino-gerrit-review/internal/app/policies"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -39,15 +38,108 @@ func stripXSSI(b []byte) []byte {
 	return b
 }
 
+// ChangeQuery describes a Gerrit change search. Empty fields are left out of the query.
+type ChangeQuery struct {
+	Project string
+	Branch  string
+	Owner   string
+	Topic   string
+	// Age uses Gerrit semantics: "age:1d" matches changes not updated for at least a day.
+	Age string
+	// Query is appended verbatim, e.g. "label:Verified+1 -is:wip".
+	Query string
+}
+
+// String renders the query in Gerrit search syntax, always restricted to open changes.
+func (q ChangeQuery) String() string {
+	parts := []string{"status:open"}
+	add := func(op, v string) {
+		if v != "" {
+			parts = append(parts, op+":"+v)
+		}
+	}
+	add("project", q.Project)
+	add("branch", q.Branch)
+	add("owner", q.Owner)
+	add("topic", q.Topic)
+	add("age", q.Age)
+	if v := strings.TrimSpace(q.Query); v != "" {
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ChangeSummary is the normalized view of a Gerrit ChangeInfo.
+type ChangeSummary struct {
+	Number          string `json:"number"`
+	Project         string `json:"project"`
+	Branch          string `json:"branch"`
+	Subject         string `json:"subject"`
+	Owner           string `json:"owner"`
+	Topic           string `json:"topic,omitempty"`
+	CurrentRevision string `json:"currentRevision"`
+	CurrentPatchset string `json:"currentPatchset"`
+	Updated         string `json:"updated"`
+}
+
+// SummarizeChange extracts the fields we care about from a raw ChangeInfo returned by QueryChanges.
+func SummarizeChange(c map[string]interface{}) ChangeSummary {
+	s := ChangeSummary{
+		Project:         getString(c, "project"),
+		Branch:          getString(c, "branch"),
+		Subject:         getString(c, "subject"),
+		Topic:           getString(c, "topic"),
+		CurrentRevision: getString(c, "current_revision"),
+		Updated:         getString(c, "updated"),
+	}
+	if n, ok := c["_number"].(float64); ok {
+		s.Number = fmt.Sprintf("%.0f", n)
+	}
+	if o, ok := c["owner"].(map[string]interface{}); ok {
+		for _, k := range []string{"username", "name", "email"} {
+			if v := getString(o, k); v != "" {
+				s.Owner = v
+				break
+			}
+		}
+		if s.Owner == "" {
+			if id, ok := o["_account_id"].(float64); ok {
+				s.Owner = fmt.Sprintf("%.0f", id)
+			}
+		}
+	}
+	if revs, ok := c["revisions"].(map[string]interface{}); ok {
+		if r, ok := revs[s.CurrentRevision].(map[string]interface{}); ok {
+			if n, ok := r["_number"].(float64); ok {
+				s.CurrentPatchset = fmt.Sprintf("%.0f", n)
+			}
+		}
+	}
+	return s
+}
+
 func (t *GerritTool) GetOpenChanges(project, branch string, limit int) ([]map[string]interface{}, error) {
+	return t.QueryChanges(ChangeQuery{Project: project, Branch: branch}, limit)
+}
+
+// QueryChanges searches open changes, asking Gerrit for the current revision and owner details.
+func (t *GerritTool) QueryChanges(q ChangeQuery, limit int) ([]map[string]interface{}, error) {
 	if t.base() == "" {
 		return []map[string]interface{}{
-			{"id": "C123", "project": "linux", "branch": "main", "subject": "fix spinlock sleep"},
-			{"id": "C456", "project": "android", "branch": "develop", "subject": "main thread sleep"},
+			{"id": "C123", "_number": float64(123), "project": "linux", "branch": "main", "subject": "fix spinlock sleep",
+				"owner": map[string]interface{}{"username": "kdev"}, "updated": "2024-01-01 00:00:00.000000000",
+				"current_revision": "a1", "revisions": map[string]interface{}{"a1": map[string]interface{}{"_number": float64(2)}}},
+			{"id": "C456", "_number": float64(456), "project": "android", "branch": "develop", "subject": "main thread sleep",
+				"owner": map[string]interface{}{"username": "adev"}, "updated": "2024-01-01 00:00:00.000000000",
+				"current_revision": "b1", "revisions": map[string]interface{}{"b1": map[string]interface{}{"_number": float64(1)}}},
 		}, nil
 	}
-	q := url.QueryEscape("status:open+project:" + project + "+branch:" + branch)
-	u := t.base() + "/a/changes/?q=" + q + "&n=" + fmt.Sprintf("%d", limit)
+	v := url.Values{}
+	v.Set("q", q.String())
+	v.Set("n", fmt.Sprintf("%d", limit))
+	v.Add("o", "CURRENT_REVISION")
+	v.Add("o", "DETAILED_ACCOUNTS")
+	u := t.base() + "/a/changes/?" + v.Encode()
 	req, _ := http.NewRequest("GET", u, nil)
 	h := t.authHeader()
 	if h != "" {
@@ -59,7 +151,7 @@ func (t *GerritTool) GetOpenChanges(project, branch string, limit int) ([]map[st
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		return nil, errors.New("gerrit changes error")
+		return nil, statusError(resp, "gerrit changes error")
 	}
 	body, _ := io.ReadAll(resp.Body)
 	body = stripXSSI(body)
@@ -70,11 +162,57 @@ func (t *GerritTool) GetOpenChanges(project, branch string, limit int) ([]map[st
 	return arr, nil
 }
 
+// GetChange fetches the summary of a single change, e.g. to learn its project and branch.
+func (t *GerritTool) GetChange(changeNum string) (ChangeSummary, error) {
+	if t.base() == "" {
+		mocks, _ := t.QueryChanges(ChangeQuery{}, 0)
+		for _, c := range mocks {
+			if s := SummarizeChange(c); s.Number == changeNum {
+				return s, nil
+			}
+		}
+		return SummarizeChange(mocks[0]), nil
+	}
+	req, _ := http.NewRequest("GET", t.base()+"/a/changes/"+url.PathEscape(changeNum)+"?o=CURRENT_REVISION", nil)
+	h := t.authHeader()
+	if h != "" {
+		req.Header.Set("Authorization", h)
+	}
+	resp, err := t.do(req)
+	if err != nil {
+		return ChangeSummary{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return ChangeSummary{}, statusError(resp, "gerrit change error")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var c map[string]interface{}
+	if err := json.Unmarshal(stripXSSI(body), &c); err != nil {
+		return ChangeSummary{}, err
+	}
+	return SummarizeChange(c), nil
+}
+
+// GetDiffs returns the diffs of a revision in the map form used by the review nodes.
 func (t *GerritTool) GetDiffs(changeNum, patchset string) ([]map[string]interface{}, error) {
+	fds, err := t.GetFileDiffs(changeNum, patchset)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]interface{}, 0, len(fds))
+	for _, fd := range fds {
+		out = append(out, fd.ToMap())
+	}
+	return out, nil
+}
+
+// GetFileDiffs fetches the typed diff of every reviewable file in a revision.
+func (t *GerritTool) GetFileDiffs(changeNum, patchset string) ([]FileDiff, error) {
 	if t.base() == "" {
-		return []map[string]interface{}{
-			{"path": "kernel/lock.c", "lang": "c", "patch": "spin_lock(&lock);\nmsleep(20);\nspin_unlock(&lock);"},
-			{"path": "app/src/main/java/com/example/MainActivity.java", "lang": "java", "patch": "public void onCreate(){\ntry{Thread.sleep(1000);}catch(Exception e){}\n}"},
+		return []FileDiff{
+			mockFileDiff("kernel/lock.c", "spin_lock(&lock);", "msleep(20);", "spin_unlock(&lock);"),
+			mockFileDiff("app/src/main/java/com/example/MainActivity.java", "public void onCreate(){", "try{Thread.sleep(1000);}catch(Exception e){}", "}"),
 		}, nil
 	}
 	filesURL := t.base() + "/a/changes/" + changeNum + "/revisions/" + patchset + "/files/"
@@ -89,18 +227,22 @@ func (t *GerritTool) GetDiffs(changeNum, patchset string) ([]map[string]interfac
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		return nil, errors.New("gerrit files error")
+		return nil, statusError(resp, "gerrit files error")
 	}
 	body, _ := io.ReadAll(resp.Body)
 	body = stripXSSI(body)
-	var files map[string]map[string]interface{}
+	var files map[string]struct {
+		Status  string `json:"status"`
+		OldPath string `json:"old_path"`
+		Binary  bool   `json:"binary"`
+	}
 	if err := json.Unmarshal(body, &files); err != nil {
 		return nil, err
 	}
-	out := make([]map[string]interface{}, 0, len(files))
+	out := make([]FileDiff, 0, len(files))
 	filter := &FileFilter{}
 
-	for p := range files {
+	for p, info := range files {
 		// Skip files that should not be reviewed
 		if filter.ShouldSkipFile(p) {
 			continue
@@ -117,63 +259,32 @@ func (t *GerritTool) GetDiffs(changeNum, patchset string) ([]map[string]interfac
 		}
 		bb, _ := io.ReadAll(rs.Body)
 		rs.Body.Close()
-		bb = stripXSSI(bb)
-		var diff map[string]interface{}
-		if json.Unmarshal(bb, &diff) == nil {
-			patch := ""
-			// Track line numbers: Gerrit diff has skip, ab, a, b fields
-			// We need to track the line number in the new file (side B)
-			lineB := 0 // Line number in the new file (after changes)
-
-			if v, ok := diff["content"].([]interface{}); ok {
-				for _, seg := range v {
-					if m, is := seg.(map[string]interface{}); is {
-						// Handle skip: lines that are omitted from context
-						if skip, ok := m["skip"].(float64); ok {
-							lineB += int(skip)
-							continue
-						}
-
-						// Gerrit diff format:
-						// "a": lines in A (deleted) - don't increment lineB
-						// "b": lines in B (added) - increment lineB
-						// "ab": lines in both (common) - increment lineB
-
-						if a, ok := m["a"].([]interface{}); ok {
-							// Deleted lines - show them but don't increment lineB
-							for _, line := range a {
-								if s, ok := line.(string); ok {
-									patch += "- " + s + "\n"
-								}
-							}
-						}
-						if b, ok := m["b"].([]interface{}); ok {
-							// Added lines - show with line numbers
-							for _, line := range b {
-								if s, ok := line.(string); ok {
-									lineB++
-									patch += fmt.Sprintf("+ [L%d] %s\n", lineB, s)
-								}
-							}
-						}
-						if ab, ok := m["ab"].([]interface{}); ok {
-							// Context lines - show with line numbers
-							for _, line := range ab {
-								if s, ok := line.(string); ok {
-									lineB++
-									patch += fmt.Sprintf("  [L%d] %s\n", lineB, s)
-								}
-							}
-						}
-					}
-				}
-			}
-			out = append(out, map[string]interface{}{"path": p, "lang": detectLang(p), "patch": patch})
+		fd, er := ParseGerritDiff(p, stripXSSI(bb))
+		if er != nil {
+			continue
 		}
+		// The file listing knows renames and binaries even when the diff body is terse.
+		if info.Status != "" {
+			fd.Change = gerritChangeType(info.Status)
+		}
+		if info.OldPath != "" {
+			fd.OldPath = info.OldPath
+		}
+		fd.Binary = fd.Binary || info.Binary
+		out = append(out, fd)
 	}
 	return out, nil
 }
 
+// mockFileDiff is a modified file whose lines were all added, for running without Gerrit.
+func mockFileDiff(path string, lines ...string) FileDiff {
+	h := Hunk{OldStart: 1, NewStart: 1}
+	for i, s := range lines {
+		h.Lines = append(h.Lines, Line{Kind: LineAdded, New: i + 1, Text: s})
+	}
+	return FileDiff{Path: path, Lang: detectLang(path), Change: ChangeModified, Hunks: []Hunk{h}}
+}
+
 func (t *GerritTool) GetFileContent(changeNum, revision, file string) (string, error) {
 	if t.base() == "" {
 		if file == "kernel/lock.c" {
@@ -196,7 +307,7 @@ func (t *GerritTool) GetFileContent(changeNum, revision, file string) (string, e
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		return "", errors.New("gerrit content error")
+		return "", statusError(resp, "gerrit content error")
 	}
 	body, _ := io.ReadAll(resp.Body)
 	body = stripXSSI(body)
@@ -233,7 +344,7 @@ func (t *GerritTool) GetFileContentFromParent(changeNum, revision, file string)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		return "", errors.New("gerrit content error")
+		return "", statusError(resp, "gerrit content error")
 	}
 	body, _ := io.ReadAll(resp.Body)
 	body = stripXSSI(body)
@@ -267,7 +378,7 @@ func (t *GerritTool) PostReview(changeNum, revision string, payload map[string]i
 	resp.Body = io.NopCloser(strings.NewReader(string(body)))
 
 	if resp.StatusCode >= 400 {
-		return resp, fmt.Errorf("gerrit post review failed: %s, body: %s", resp.Status, string(body))
+		return resp, fmt.Errorf("%w, body: %s", statusError(resp, "gerrit post review failed"), string(body))
 	}
 	return resp, nil
 }
@@ -299,15 +410,12 @@ func (t *GerritTool) do(req *http.Request) (*http.Response, error) {
 			return resp, nil
 		}
 		if resp != nil {
+			last = statusError(resp, "gerrit request failed")
 			resp.Body.Close()
+		} else {
+			last = fmt.Errorf("%w: %v", ErrNetwork, err)
 		}
-		last = err
 		time.Sleep(time.Duration(200*(i+1)) * time.Millisecond)
 	}
-	if last != nil {
-		return nil, last
-	}
-	return nil, errors.New("request failed")
+	return nil, last
 }
-
-//
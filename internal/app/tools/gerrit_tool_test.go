@@ -1,9 +1,51 @@
 package tools
 
-import "testing"
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
 
 func TestStripXSSI(t *testing.T) {
     b := []byte(")]}'\n{\"a\":1}")
     out := stripXSSI(b)
     if string(out) != "{\"a\":1}" { t.Fatalf("stripXSSI failed: %s", string(out)) }
 }
+
+func TestChangeQueryString(t *testing.T) {
+	q := ChangeQuery{Project: "platform/base", Branch: "main", Owner: "alice", Age: "2d", Query: "-is:wip"}
+	want := "status:open project:platform/base branch:main owner:alice age:2d -is:wip"
+	if got := q.String(); got != want {
+		t.Fatalf("query = %q, want %q", got, want)
+	}
+}
+
+func TestSummarizeChange(t *testing.T) {
+	c := map[string]interface{}{
+		"_number": float64(77), "subject": "s", "current_revision": "abc",
+		"owner":     map[string]interface{}{"_account_id": float64(9), "name": "Alice"},
+		"revisions": map[string]interface{}{"abc": map[string]interface{}{"_number": float64(4)}},
+	}
+	s := SummarizeChange(c)
+	if s.Number != "77" || s.Owner != "Alice" || s.CurrentPatchset != "4" || s.CurrentRevision != "abc" {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	resp := &http.Response{StatusCode: 503, Status: "503 Service Unavailable"}
+	if c := ErrorClass(statusError(resp, "x")); c != ClassGerritUnavailable {
+		t.Fatalf("503 class = %q", c)
+	}
+	resp = &http.Response{StatusCode: 429, Status: "429 Too Many Requests"}
+	if c := ErrorClass(statusError(resp, "x")); c != ClassRateLimited {
+		t.Fatalf("429 class = %q", c)
+	}
+	resp = &http.Response{StatusCode: 404, Status: "404 Not Found"}
+	if c := ErrorClass(statusError(resp, "x")); c != "" {
+		t.Fatalf("404 should not be retryable, got %q", c)
+	}
+	if c := ErrorClass(fmt.Errorf("%w: dial tcp", ErrNetwork)); c != ClassNetwork {
+		t.Fatalf("network class = %q", c)
+	}
+}
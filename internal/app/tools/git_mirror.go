@@ -0,0 +1,276 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"eino-gerrit-review/internal/config"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// ContentSource reads the files of a change revision. GerritTool is the
+// default; GitMirror reads the same from a local bare clone.
+type ContentSource interface {
+	GetFileContent(changeNum, revision, file string) (string, error)
+	GetFileContentFromParent(changeNum, revision, file string) (string, error)
+}
+
+// mirrorFetchTimeout bounds one git fetch of a change ref.
+const mirrorFetchTimeout = 2 * time.Minute
+
+// mirrorLocks serialises fetches into the same bare repository.
+var mirrorLocks sync.Map
+
+var shaRe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// GitMirror serves change contents from the bare repository Dir, fetching
+// refs/changes/NN/<change>/<patchset> from Remote on first use.
+type GitMirror struct {
+	Dir    string
+	Remote string
+	// Header is sent as Authorization to http(s) remotes.
+	Header string
+}
+
+// gitMirrorFor returns the mirror of project under GIT_MIRROR_DIR, or nil when
+// mirroring is off or project is not listed in GIT_MIRROR_PROJECTS. The remote
+// is GIT_MIRROR_URL/<project>, by default Gerrit's authenticated /a/ endpoint.
+func gitMirrorFor(project string) *GitMirror {
+	root := os.Getenv("GIT_MIRROR_DIR")
+	if root == "" || project == "" {
+		return nil
+	}
+	listed := false
+	for _, g := range strings.Split(os.Getenv("GIT_MIRROR_PROJECTS"), ",") {
+		if g = strings.TrimSpace(g); g != "" && config.MatchFullGlob(g, project) {
+			listed = true
+			break
+		}
+	}
+	if !listed {
+		return nil
+	}
+	g := &GerritTool{}
+	remote := strings.TrimRight(os.Getenv("GIT_MIRROR_URL"), "/")
+	if remote == "" {
+		if g.base() == "" {
+			return nil
+		}
+		remote = g.base() + "/a"
+	}
+	m := &GitMirror{
+		Dir:    filepath.Join(root, filepath.FromSlash(project)+".git"),
+		Remote: remote + "/" + project,
+	}
+	if strings.HasPrefix(m.Remote, "http://") || strings.HasPrefix(m.Remote, "https://") {
+		m.Header = g.authHeader()
+	}
+	return m
+}
+
+func (m *GitMirror) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", m.Dir}, args...)...)
+	// The header goes through the environment so it never shows in ps.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if m.Header != "" {
+		cmd.Env = append(cmd.Env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: "+m.Header)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// Resolve returns the commit of a patch set, fetching its change ref into the
+// mirror when it is not there yet. revision is a patch set number or a commit
+// SHA already in the mirror.
+func (m *GitMirror) Resolve(changeNum, revision string) (string, error) {
+	ctx := context.Background()
+	if shaRe.MatchString(revision) {
+		return m.revParse(ctx, revision)
+	}
+	n, err1 := strconv.Atoi(changeNum)
+	ps, err2 := strconv.Atoi(revision)
+	if err1 != nil || err2 != nil || n <= 0 || ps <= 0 {
+		return "", fmt.Errorf("git mirror: cannot map change %q revision %q to a ref", changeNum, revision)
+	}
+	ref := fmt.Sprintf("refs/changes/%02d/%d/%d", n%100, n, ps)
+	if c, err := m.revParse(ctx, ref); err == nil {
+		return c, nil
+	}
+	mu, _ := mirrorLocks.LoadOrStore(m.Dir, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+	if c, err := m.revParse(ctx, ref); err == nil {
+		return c, nil
+	}
+	if _, err := os.Stat(m.Dir); os.IsNotExist(err) {
+		if out, err := exec.Command("git", "init", "-q", "--bare", m.Dir).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git init: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	fctx, cancel := context.WithTimeout(ctx, mirrorFetchTimeout)
+	defer cancel()
+	// Patch set refs never move, so there is nothing to force.
+	if _, err := m.git(fctx, "fetch", "-q", "--no-tags", m.Remote, ref+":"+ref); err != nil {
+		return "", err
+	}
+	return m.revParse(ctx, ref)
+}
+
+func (m *GitMirror) revParse(ctx context.Context, rev string) (string, error) {
+	out, err := m.git(ctx, "rev-parse", "-q", "--verify", rev+"^{commit}")
+	return strings.TrimSpace(out), err
+}
+
+// show returns file at commit, or ErrNotFound when the commit exists but has
+// no such path. Any other failure is returned as it is, so callers can fall
+// back to Gerrit.
+func (m *GitMirror) show(commit, file string) (string, error) {
+	ctx := context.Background()
+	out, err := m.git(ctx, "cat-file", "blob", commit+":"+file)
+	if err == nil {
+		return out, nil
+	}
+	if _, rerr := m.revParse(ctx, commit); rerr != nil {
+		return "", err
+	}
+	if ls, lerr := m.git(ctx, "ls-tree", "--name-only", commit, "--", file); lerr == nil && strings.TrimSpace(ls) == "" {
+		return "", fmt.Errorf("%s at %.12s: %w", file, commit, ErrNotFound)
+	}
+	return "", err
+}
+
+// GetFileContent returns file as of the patch set.
+func (m *GitMirror) GetFileContent(changeNum, revision, file string) (string, error) {
+	c, err := m.Resolve(changeNum, revision)
+	if err != nil {
+		return "", err
+	}
+	return m.show(c, file)
+}
+
+// GetFileContentFromParent returns file as of the patch set's first parent.
+func (m *GitMirror) GetFileContentFromParent(changeNum, revision, file string) (string, error) {
+	c, err := m.Resolve(changeNum, revision)
+	if err != nil {
+		return "", err
+	}
+	return m.show(c+"^1", file)
+}
+
+// BlameLine is one line of git blame output.
+type BlameLine struct {
+	Line    int
+	Commit  string
+	Author  string
+	Summary string
+	Text    string
+}
+
+// Blame returns who last touched lines start to end of file at commit.
+func (m *GitMirror) Blame(commit, file string, start, end int) ([]BlameLine, error) {
+	out, err := m.git(context.Background(), "blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", start, end), commit, "--", file)
+	if err != nil {
+		return nil, err
+	}
+	type info struct{ author, summary string }
+	commits := make(map[string]*info)
+	var res []BlameLine
+	var cur BlameLine
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		l := sc.Text()
+		switch {
+		case strings.HasPrefix(l, "\t"):
+			cur.Text = l[1:]
+			if c := commits[cur.Commit]; c != nil {
+				cur.Author, cur.Summary = c.author, c.summary
+			}
+			res = append(res, cur)
+		case strings.HasPrefix(l, "author "):
+			commits[cur.Commit].author = l[len("author "):]
+		case strings.HasPrefix(l, "summary "):
+			commits[cur.Commit].summary = l[len("summary "):]
+		default:
+			// "<sha> <orig-line> <final-line> [<group-size>]" starts each entry.
+			f := strings.Fields(l)
+			if len(f) >= 3 && shaRe.MatchString(f[0]) {
+				n, _ := strconv.Atoi(f[2])
+				cur = BlameLine{Commit: f[0], Line: n}
+				if commits[f[0]] == nil {
+					commits[f[0]] = &info{}
+				}
+			}
+		}
+	}
+	return res, sc.Err()
+}
+
+// blameContexts returns, as "blame" context, who last changed the base lines
+// the diff of fd deletes or rewrites, at most limit lines in all, so the
+// model sees whose code and which commit the change replaces.
+func blameContexts(m *GitMirror, commit string, fd FileDiff, limit int) []ContextInfo {
+	path := fd.OldPath
+	if path == "" {
+		path = fd.Path
+	}
+	var out []ContextInfo
+	for _, r := range editedRanges(fd, LineDeleted) {
+		if limit <= 0 {
+			break
+		}
+		end := min(r.End, r.Start+limit-1)
+		lines, err := m.Blame(commit+"^1", path, r.Start, end)
+		if err != nil {
+			g.Log().Warningf(context.Background(), "git blame of %s failed: %v", path, err)
+			break
+		}
+		var b strings.Builder
+		for _, l := range lines {
+			fmt.Fprintf(&b, "[L%d] %.10s %s「%s」| %s\n", l.Line, l.Commit, l.Author, l.Summary, l.Text)
+		}
+		limit -= len(lines)
+		out = append(out, ContextInfo{
+			FilePath:    fd.Path,
+			ContextType: "blame",
+			Content:     strings.TrimSuffix(b.String(), "\n"),
+			StartLine:   r.Start,
+			EndLine:     end,
+			Source:      "mirror",
+		})
+	}
+	return out
+}
+
+// references returns the tree at commit as a reference source for callers and
+// callees, searched with git grep.
+func (m *GitMirror) references(commit string) referenceSource {
+	return mirrorTree{m: m, commit: commit}
+}
+
+type mirrorTree struct {
+	m      *GitMirror
+	commit string
+}
+
+func (t mirrorTree) Name() string { return "mirror" }
+
+func (t mirrorTree) Files(names []string, fn func(path, content string)) error {
+	read := func(p string) (string, error) { return t.m.show(t.commit, p) }
+	return grepRefs(t.m.git, t.commit, names, read, fn)
+}
@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/testutil"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitFixture builds a bare "Gerrit" repository at remote holding change 12345
+// patch set 1 on top of a base commit.
+func gitFixture(t *testing.T, remote string) {
+	t.Helper()
+	testutil.GitRemote(t, remote, "refs/changes/45/12345/1",
+		testutil.Commit{Message: "base", Files: map[string]string{
+			"lib.c":  "int compute(int x)\n{\n\treturn x;\n}\n",
+			"main.c": "int main(void)\n{\n\treturn compute(1);\n}\n",
+		}},
+		testutil.Commit{Message: "bump compute", Files: map[string]string{
+			"lib.c": "int compute(int x)\n{\n\treturn x + 1;\n}\n",
+			"new.c": "void added(void) { }\n",
+		}})
+}
+
+func TestGitMirror(t *testing.T) {
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	gitFixture(t, remote)
+	m := &GitMirror{Dir: filepath.Join(t.TempDir(), "project.git"), Remote: remote}
+
+	got, err := m.GetFileContent("12345", "1", "lib.c")
+	if err != nil || !strings.Contains(got, "x + 1") {
+		t.Fatalf("new content %q, %v", got, err)
+	}
+	if got, err := m.GetFileContentFromParent("12345", "1", "lib.c"); err != nil || strings.Contains(got, "x + 1") {
+		t.Fatalf("parent content %q, %v", got, err)
+	}
+	if _, err := m.GetFileContentFromParent("12345", "1", "new.c"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("added file in parent: %v", err)
+	}
+	if _, err := m.GetFileContent("12345", "2", "lib.c"); err == nil {
+		t.Fatalf("unknown patch set should fail")
+	}
+
+	commit, err := m.Resolve("12345", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := m.Resolve("12345", commit); err != nil || c != commit {
+		t.Fatalf("resolve by sha: %q, %v", c, err)
+	}
+	// Only a path missing from a readable commit is ErrNotFound; a broken
+	// mirror must let readFile fall back to Gerrit.
+	broken := &GitMirror{Dir: filepath.Join(t.TempDir(), "gone.git")}
+	if _, err := broken.show(commit, "lib.c"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("broken mirror: %v", err)
+	}
+	if f, err := readFile(broken, stubSource{"from gerrit"}, "12345", commit, "lib.c", false); err != nil || f.source != "gerrit" || f.content != "from gerrit" {
+		t.Fatalf("no fallback: %+v, %v", f, err)
+	}
+	blame, err := m.Blame(commit, "lib.c", 2, 3)
+	if err != nil || len(blame) != 2 {
+		t.Fatalf("blame %+v, %v", blame, err)
+	}
+	if blame[0].Summary != "base" || blame[1].Summary != "bump compute" || blame[1].Author != "Ann" || blame[1].Line != 3 || blame[1].Text != "\treturn x + 1;" {
+		t.Fatalf("blame %+v", blame)
+	}
+
+	var files []string
+	if err := m.references(commit).Files([]string{"compute"}, func(p, _ string) { files = append(files, p) }); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(files, ",") != "lib.c,main.c" {
+		t.Fatalf("grep found %v", files)
+	}
+	if err := m.references(commit).Files([]string{"nowhere"}, func(p, _ string) { t.Fatalf("unexpected %s", p) }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchReadsFromGitMirror(t *testing.T) {
+	base := t.TempDir()
+	gitFixture(t, filepath.Join(base, "platform", "lib"))
+	t.Setenv("GIT_MIRROR_DIR", t.TempDir())
+	t.Setenv("GIT_MIRROR_PROJECTS", "platform/*")
+	t.Setenv("GIT_MIRROR_URL", base)
+	if gitMirrorFor("other/lib") != nil {
+		t.Fatalf("unlisted projects must not be mirrored")
+	}
+
+	diffs := []map[string]interface{}{{"path": "lib.c", "patch": "  [L1] int compute(int x)\n  [L2] {\n- \treturn x;\n+ [L3] \treturn x + 1;\n  [L4] }"}}
+	out := (&CodeContextTool{Project: "platform/lib"}).Fetch(true, "12345", "1", diffs)
+	if len(out) != 2 {
+		t.Fatalf("got %+v", out)
+	}
+	if out[0].Source != "mirror" || !strings.Contains(out[0].Content, "x + 1") {
+		t.Fatalf("file context %+v", out[0])
+	}
+	if c := out[1]; c.ContextType != "callers" || c.FilePath != "main.c" || c.Source != "mirror" {
+		t.Fatalf("caller context %+v", c)
+	}
+}
+
+func TestFetchComparesWithParent(t *testing.T) {
+	base := t.TempDir()
+	gitFixture(t, filepath.Join(base, "platform", "cmp"))
+	t.Setenv("GIT_MIRROR_DIR", t.TempDir())
+	t.Setenv("GIT_MIRROR_PROJECTS", "platform/cmp")
+	t.Setenv("GIT_MIRROR_URL", base)
+	t.Setenv("CONTEXT_GRANULARITY", "compare")
+	t.Setenv("CONTEXT_CALLERS_LIMIT", "0")
+	t.Setenv("CONTEXT_BLAME_LINES", "0")
+
+	fd := FileDiff{Path: "lib.c", Change: ChangeModified, Hunks: []Hunk{{OldStart: 1, NewStart: 1, Lines: []Line{
+		{Kind: LineContext, Old: 1, New: 1}, {Kind: LineContext, Old: 2, New: 2},
+		{Kind: LineDeleted, Old: 3}, {Kind: LineAdded, New: 3},
+		{Kind: LineContext, Old: 4, New: 4},
+	}}}}
+	out := (&CodeContextTool{Project: "platform/cmp"}).Fetch(true, "12345", "1", []map[string]interface{}{{"path": "lib.c", "diff": fd}})
+	if len(out) != 1 {
+		t.Fatalf("got %+v", out)
+	}
+	c := out[0]
+	if c.ContextType != "compare" || c.Symbol != "compute" || !strings.Contains(c.Content, "x + 1") || strings.Contains(c.OldContent, "x + 1") || c.OldStartLine != 1 {
+		t.Fatalf("compare context %+v", c)
+	}
+}
+
+func TestFetchBlamesRewrittenLines(t *testing.T) {
+	base := t.TempDir()
+	gitFixture(t, filepath.Join(base, "platform", "blame"))
+	t.Setenv("GIT_MIRROR_DIR", t.TempDir())
+	t.Setenv("GIT_MIRROR_PROJECTS", "platform/blame")
+	t.Setenv("GIT_MIRROR_URL", base)
+	t.Setenv("CONTEXT_GRANULARITY", "file")
+	t.Setenv("CONTEXT_CALLERS_LIMIT", "0")
+
+	fd := FileDiff{Path: "lib.c", Change: ChangeModified, Hunks: []Hunk{{OldStart: 1, NewStart: 1, Lines: []Line{
+		{Kind: LineContext, Old: 2, New: 2}, {Kind: LineDeleted, Old: 3}, {Kind: LineAdded, New: 3},
+	}}}}
+	diffs := []map[string]interface{}{{"path": "lib.c", "diff": fd}}
+	out := (&CodeContextTool{Project: "platform/blame"}).Fetch(true, "12345", "1", diffs)
+	if len(out) != 2 {
+		t.Fatalf("got %+v", out)
+	}
+	if c := out[1]; c.ContextType != "blame" || c.StartLine != 3 || c.EndLine != 3 || !strings.Contains(c.Content, "Ann「base」| \treturn x;") {
+		t.Fatalf("blame context %+v", c)
+	}
+	if p := BuildPrompt("", out); !strings.Contains(p, "变更前第 3-3 行的最近修改记录") {
+		t.Fatalf("blame missing from prompt")
+	}
+
+	t.Setenv("CONTEXT_BLAME_LINES", "0")
+	FlushContextCache("platform/blame")
+	if out := (&CodeContextTool{Project: "platform/blame"}).Fetch(true, "12345", "1", diffs); len(out) != 1 {
+		t.Fatalf("blame not disabled: %+v", out)
+	}
+}
+
+// stubSource serves the same content for every file.
+type stubSource struct{ content string }
+
+func (s stubSource) GetFileContent(_, _, _ string) (string, error) { return s.content, nil }
+
+func (s stubSource) GetFileContentFromParent(_, _, _ string) (string, error) { return s.content, nil }
@@ -1,35 +1,36 @@
 package tools
 
-import (
-    "regexp"
-    "strings"
-)
-
 type LanguageAdapter interface{
     ExtractFunction(src string) string
     ExtractClass(src string) string
     ExtractDependencies(src string) string
+    // Outline lists the classes and functions with a body in src, in source order.
+    Outline(src string) []Symbol
 }
 
 type CAdapter struct{}
-func (CAdapter) ExtractFunction(s string) string { return findFirstBlockByRegex(s, `(?m)^\s*[\w\*\[\]]+[\s\*]+[\w_]+\s*\([^\)]*\)\s*\{`) }
-func (CAdapter) ExtractClass(s string) string { return "" }
+func (CAdapter) ExtractFunction(s string) string { return firstSymbol(s, familyC, SymbolFunction, limitSize(s)) }
+func (CAdapter) ExtractClass(s string) string { return firstSymbol(s, familyC, SymbolClass, "") }
 func (CAdapter) ExtractDependencies(s string) string { return extractDependencies(s) }
+func (CAdapter) Outline(s string) []Symbol { return outline(s, familyC) }
 
 type JavaAdapter struct{}
-func (JavaAdapter) ExtractFunction(s string) string { return findFirstBlockByRegex(s, `(?m)^\s*(public|protected|private)?\s*(static\s+)?[\w\<\>\[\]]+\s+[\w_]+\s*\([^\)]*\)\s*\{`) }
-func (JavaAdapter) ExtractClass(s string) string { return findFirstBlockByRegex(s, `(?m)^\s*(public|protected|private)?\s*(final|abstract)?\s*class\s+[\w_]+[^\n]*\{`) }
+func (JavaAdapter) ExtractFunction(s string) string { return firstSymbol(s, familyJava, SymbolFunction, limitSize(s)) }
+func (JavaAdapter) ExtractClass(s string) string { return firstSymbol(s, familyJava, SymbolClass, limitSize(s)) }
 func (JavaAdapter) ExtractDependencies(s string) string { return extractDependencies(s) }
+func (JavaAdapter) Outline(s string) []Symbol { return outline(s, familyJava) }
 
 type KotlinAdapter struct{}
-func (KotlinAdapter) ExtractFunction(s string) string { return findFirstBlockByRegex(s, `(?m)^\s*fun\s+[\w_]+\s*\([^\)]*\)\s*\{`) }
-func (KotlinAdapter) ExtractClass(s string) string { return findFirstBlockByRegex(s, `(?m)^\s*(open\s+)?class\s+[\w_]+[^\n]*\{`) }
+func (KotlinAdapter) ExtractFunction(s string) string { return firstSymbol(s, familyKotlin, SymbolFunction, limitSize(s)) }
+func (KotlinAdapter) ExtractClass(s string) string { return firstSymbol(s, familyKotlin, SymbolClass, limitSize(s)) }
 func (KotlinAdapter) ExtractDependencies(s string) string { return extractDependencies(s) }
+func (KotlinAdapter) Outline(s string) []Symbol { return outline(s, familyKotlin) }
 
 type DefaultAdapter struct{}
 func (DefaultAdapter) ExtractFunction(s string) string { return limitSize(s) }
 func (DefaultAdapter) ExtractClass(s string) string { return limitSize(s) }
 func (DefaultAdapter) ExtractDependencies(s string) string { return extractDependencies(s) }
+func (DefaultAdapter) Outline(s string) []Symbol { return nil }
 
 func adapterForPath(p string) LanguageAdapter {
     if hasSuffix(p, ".c") || hasSuffix(p, ".h") || hasSuffix(p, ".cpp") || hasSuffix(p, ".hpp") { return CAdapter{} }
@@ -38,6 +39,19 @@ func adapterForPath(p string) LanguageAdapter {
     return DefaultAdapter{}
 }
 
+// familyFor returns the lexical family of the adapter for p, if it parses code.
+func familyFor(p string) (langFamily, bool) {
+    switch adapterForPath(p).(type) {
+    case CAdapter:
+        return familyC, true
+    case JavaAdapter:
+        return familyJava, true
+    case KotlinAdapter:
+        return familyKotlin, true
+    }
+    return 0, false
+}
+
 func hasSuffix(s, suf string) bool {
     n := len(s)
     m := len(suf)
@@ -45,19 +59,19 @@ func hasSuffix(s, suf string) bool {
     return s[n-m:] == suf
 }
 
-func findFirstBlockByRegex(s string, pattern string) string {
-    re := regexp.MustCompile(pattern)
-    loc := re.FindStringIndex(s)
-    if loc == nil { return limitSize(s) }
-    brace := strings.Index(s[loc[1]:], "{")
-    if brace < 0 { return limitSize(s) }
-    start := loc[1] + brace
-    depth := 0
-    end := len(s)
-    for i := start; i < len(s); i++ {
-        if s[i] == '{' { depth++ }
-        if s[i] == '}' { depth--; if depth == 0 { end = i+1; break } }
+// firstSymbol returns the text of the first symbol of kind in s, or def.
+func firstSymbol(s string, fam langFamily, kind SymbolKind, def string) string {
+    for _, sym := range outline(s, fam) {
+        if sym.Kind == kind { return symbolText(s, sym) }
+    }
+    return def
+}
+
+// Functions returns the function symbols of an outline.
+func Functions(syms []Symbol) []Symbol {
+    var out []Symbol
+    for _, s := range syms {
+        if s.Kind == SymbolFunction { out = append(out, s) }
     }
-    if end <= start { return limitSize(s) }
-    return s[loc[0]:end]
+    return out
 }
@@ -1,6 +1,9 @@
 package tools
 
-import "testing"
+import (
+    "reflect"
+    "testing"
+)
 
 func TestCFunctionExtract(t *testing.T) {
     src := "int add(int a,int b){\nreturn a+b;\n}\n"
@@ -14,3 +17,41 @@ func TestJavaClassExtract(t *testing.T) {
     if len(got) == 0 { t.Fatalf("empty class extract") }
 }
 
+
+func TestOutline(t *testing.T) {
+    fn := func(name, class string, start, end int) Symbol { return Symbol{SymbolFunction, name, class, start, end} }
+    cls := func(name, class string, start, end int) Symbol { return Symbol{SymbolClass, name, class, start, end} }
+    cases := []struct {
+        name, path, src string
+        want            []Symbol
+    }{
+        {"c", "a.c", "#include <x.h>\n\nstatic int add(int a,\n               int b)\n{\n    if (a) {\n        return a;\n    }\n    return a + b;\n}\n\nvoid Foo::bar(void) { }\n",
+            []Symbol{fn("add", "", 3, 10), fn("bar", "Foo", 12, 12)}},
+        {"java", "A.java", "public class MainActivity {\n    @Override\n    protected void onCreate(Bundle b) {\n        if (b != null) {\n        }\n    }\n    static class Inner {\n        Inner() {\n        }\n    }\n}\n",
+            []Symbol{cls("MainActivity", "", 1, 11), fn("onCreate", "MainActivity", 2, 6), cls("Inner", "MainActivity", 7, 10), fn("Inner", "Inner", 8, 9)}},
+        {"kotlin", "A.kt", "class Repo {\n    suspend fun load(id: Int = 0): List<Item> {\n        return when (id) { else -> emptyList() }\n    }\n    fun size() = 0\n}\n",
+            []Symbol{cls("Repo", "", 1, 6), fn("load", "Repo", 2, 4)}},
+        {"c braces in literals", "a.c", "#define OPEN {\nconst char *s = \"}\";\nint f(void) {\n    char c = '}';\n    /* } */ // {\n    return '{';\n}\nint g(void) { list_for_each(p, h) { } }\n",
+            []Symbol{fn("f", "", 3, 7), fn("g", "", 8, 8)}},
+        {"cpp class and raw string", "a.cpp", "class Parser : public Base {\npublic:\n    Parser() : n_(0) {}\n    ~Parser() { auto s = R\"x(})x\"; }\n};\n",
+            []Symbol{cls("Parser", "", 1, 5), fn("Parser", "Parser", 3, 3), fn("~Parser", "Parser", 4, 4)}},
+        {"java text block and anonymous class", "A.java", "class A {\n    String q = \"\"\"\n        }\n        \"\"\";\n    void run() {\n        new Thread() {\n            public void run() { }\n        }.start();\n    }\n    static { init(); }\n}\n",
+            []Symbol{cls("A", "", 1, 11), fn("run", "A", 5, 9), fn("run", "A", 7, 7)}},
+        {"kotlin templates and objects", "A.kt", "object Cache {\n    val s = \"${map { \"}\" }}\"\n    /* outer /* } */ */\n    fun get(k: String): String {\n        return \"\"\"{$k\"\"\"\n    }\n    companion object {\n        fun String.twice() = this + this\n    }\n}\n",
+            []Symbol{cls("Cache", "", 1, 10), fn("get", "Cache", 4, 6), cls("Companion", "Cache", 7, 9)}},
+        {"other", "a.py", "def f():\n    pass\n", nil},
+    }
+    for _, c := range cases {
+        got := adapterForPath(c.path).Outline(c.src)
+        if !reflect.DeepEqual(got, c.want) {
+            t.Fatalf("%s: got %+v, want %+v", c.name, got, c.want)
+        }
+    }
+}
+
+func TestExtractFunctionSkipsCommentedCode(t *testing.T) {
+    src := "// int old(void) {\n/* void dead() { } */\nint live(void) {\n    return 0;\n}\n"
+    if got, want := (CAdapter{}).ExtractFunction(src), "int live(void) {\n    return 0;\n}"; got != want {
+        t.Fatalf("got %q, want %q", got, want)
+    }
+}
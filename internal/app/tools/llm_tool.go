@@ -3,9 +3,13 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/schema"
@@ -30,27 +34,26 @@ func (t *LLMTool) Generate(prompt string) ([]LLMAdvice, error) {
 	}
 
 	baseURL := os.Getenv("OPENAI_BASE_URL")
-	modelName := os.Getenv("MODEL_NAME")
-	if modelName == "" {
-		modelName = "gpt-4o"
-	}
 
 	conf := &openai.ChatModelConfig{
 		BaseURL: baseURL,
 		APIKey:  apiKey,
-		Model:   modelName,
+		Model:   ModelName(),
 	}
 
-	cm, err := openai.NewChatModel(context.Background(), conf)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(atoi(getenv("LLM_TIMEOUT_SECONDS", "180")))*time.Second)
+	defer cancel()
+
+	cm, err := openai.NewChatModel(ctx, conf)
 	if err != nil {
 		return nil, err
 	}
 
-	stream, err := cm.Stream(context.Background(), []*schema.Message{
+	stream, err := cm.Stream(ctx, []*schema.Message{
 		schema.UserMessage(prompt),
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyLLMError(ctx, err)
 	}
 	defer stream.Close()
 
@@ -60,14 +63,10 @@ func (t *LLMTool) Generate(prompt string) ([]LLMAdvice, error) {
 	for {
 		chunk, err := stream.Recv()
 		if err != nil {
-			if err.Error() == "EOF" {
+			if errors.Is(err, io.EOF) {
 				break
 			}
-			// If EOF is not returned as error string but as io.EOF, we should handle it.
-			// However, eino stream Recv usually returns error on finish or failure.
-			// Let's assume standard behavior: err != nil means stop.
-			// Check if it is EOF
-			break
+			return nil, classifyLLMError(ctx, err)
 		}
 		chunkCount++
 		sb.WriteString(chunk.Content)
@@ -137,6 +136,31 @@ func (t *LLMTool) Generate(prompt string) ([]LLMAdvice, error) {
 	return advice, nil
 }
 
+// ModelName returns the chat model used for review generation.
+func ModelName() string {
+	if v := os.Getenv("MODEL_NAME"); v != "" {
+		return v
+	}
+	return "gpt-4o"
+}
+
+// classifyLLMError wraps model errors with ErrModelTimeout or ErrRateLimited
+// so the scheduler can tell transient failures from permanent ones.
+func classifyLLMError(ctx context.Context, err error) error {
+	msg := strings.ToLower(err.Error())
+	var ne net.Error
+	switch {
+	case ctx.Err() == context.DeadlineExceeded, errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &ne) && ne.Timeout(), strings.Contains(msg, "timeout"):
+		return fmt.Errorf("%w: %v", ErrModelTimeout, err)
+	case strings.Contains(msg, "429") || strings.Contains(msg, "rate limit"):
+		return fmt.Errorf("%w: %v", ErrRateLimited, err)
+	case errors.As(err, &ne):
+		return fmt.Errorf("%w: %v", ErrNetwork, err)
+	}
+	return err
+}
+
 func getString(m map[string]interface{}, key string) string {
 	if v, ok := m[key].(string); ok {
 		return v
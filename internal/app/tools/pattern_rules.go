@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/config"
+	"regexp"
+	"strings"
+)
+
+// patternRule adapts a declarative rule from rules.json to the Rule interface.
+type patternRule struct{ def config.PatternRule }
+
+func (r patternRule) ID() string          { return r.def.ID }
+func (r patternRule) Languages() []string { return r.def.Languages }
+func (r patternRule) PathGlobs() []string { return r.def.Include }
+func (r patternRule) Severity() string    { return r.def.Severity }
+
+func (r patternRule) Check(f *RuleFile) []Finding {
+	for _, g := range r.def.Exclude {
+		if config.MatchGlob(g, f.Path) {
+			return nil
+		}
+	}
+	re := r.def.PatternRe()
+	if re == nil {
+		return nil
+	}
+	var out []Finding
+	for _, l := range f.Added {
+		loc := re.FindStringIndex(l.Text)
+		if loc == nil {
+			continue
+		}
+		if w := r.def.WithinRe(); w != nil && !insideBlock(f.Above(l.New, loc[0]), w) {
+			continue
+		}
+		out = append(out, Finding{Line: l.New, Title: r.def.Title, Detail: r.def.Detail, Suggest: r.def.Suggest})
+	}
+	return out
+}
+
+// insideBlock reports whether the end of lines lies inside a brace block whose
+// header matches re. The header is the text before "{" on its line, or the
+// previous non-blank line when the brace stands alone.
+func insideBlock(lines []string, re *regexp.Regexp) bool {
+	var open []string
+	prev := ""
+	for _, s := range lines {
+		for i := 0; i < len(s); i++ {
+			switch s[i] {
+			case '{':
+				h := strings.TrimSpace(s[:i])
+				if h == "" {
+					h = prev
+				}
+				open = append(open, h)
+			case '}':
+				if len(open) > 0 {
+					open = open[:len(open)-1]
+				}
+			}
+		}
+		if t := strings.TrimSpace(s); t != "" {
+			prev = t
+		}
+	}
+	for _, h := range open {
+		if re.MatchString(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// activeRules returns the registered rules plus the profile's declarative ones,
+// minus those the profile disables; a declarative rule replaces a registered
+// rule with the same ID.
+func activeRules(p config.RuleProfile) []Rule {
+	defs := p.Rules
+	override := make(map[string]bool, len(defs)+len(p.DisabledRules))
+	for _, id := range p.DisabledRules {
+		override[id] = true
+	}
+	for _, d := range defs {
+		override[d.ID] = true
+	}
+	var out []Rule
+	for _, r := range Rules() {
+		if !override[r.ID()] {
+			out = append(out, r)
+		}
+	}
+	for _, d := range defs {
+		out = append(out, patternRule{d})
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/config"
+	"testing"
+)
+
+func TestPatternRules(t *testing.T) {
+	_, defs, err := config.ParseRuleConfig([]byte(`{
+	  "version": 2,
+	  "rules": [
+	    {"id": "no-printk", "languages": ["c"], "include": ["drivers/**"], "exclude": ["**/debug/**"],
+	     "pattern": "\\bprintk\\(", "severity": "low", "title": "printk", "suggest": "use dev_info"},
+	    {"id": "irq-alloc", "pattern": "GFP_KERNEL", "within": "_irq_handler\\(", "severity": "high", "title": "sleeping alloc in irq"}
+	  ]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetRuleSwitches(config.RuleSwitches{})
+	config.SetPatternRules(defs)
+	defer config.SetPatternRules(nil)
+
+	irq := FileDiff{Path: "drivers/net/eth.c", Hunks: []Hunk{{OldStart: 1, NewStart: 1, Lines: []Line{
+		{Kind: LineContext, New: 1, Text: "static irqreturn_t eth_irq_handler(int irq, void *dev)"},
+		{Kind: LineContext, New: 2, Text: "{"},
+		{Kind: LineAdded, New: 3, Text: "\tp = kmalloc(n, GFP_KERNEL);"},
+		{Kind: LineContext, New: 4, Text: "}"},
+		{Kind: LineAdded, New: 5, Text: "static void *eth_alloc(void) { return kmalloc(n, GFP_KERNEL); }"},
+		{Kind: LineAdded, New: 6, Text: "\tprintk(\"up\");"},
+	}}}}
+	diffs := []map[string]interface{}{
+		irq.ToMap(),
+		mockFileDiff("drivers/debug/trace.c", "printk(\"x\");").ToMap(),
+		mockFileDiff("kernel/sched.c", "printk(\"x\");").ToMap(),
+	}
+	adv := (&StaticRuleTool{}).Run(diffs, nil)
+	if len(adv) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", adv)
+	}
+	for _, a := range adv {
+		switch a.Rule {
+		case "irq-alloc":
+			if a.Line != 3 || a.Severity != "high" {
+				t.Fatalf("unexpected irq finding: %+v", a)
+			}
+		case "no-printk":
+			if a.Line != 6 || a.File != "drivers/net/eth.c" || a.Suggest != "use dev_info" {
+				t.Fatalf("unexpected printk finding: %+v", a)
+			}
+		default:
+			t.Fatalf("unexpected rule: %+v", a)
+		}
+	}
+}
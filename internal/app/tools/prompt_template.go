@@ -48,7 +48,21 @@ func BuildPrompt(diff string, ctxs []ContextInfo) string {
 	if len(ctxs) > 0 {
 		p += "上下文 (Context):\n"
 		for i := range ctxs {
-			p += "文件: " + ctxs[i].FilePath + "\n"
+			switch c := ctxs[i]; c.ContextType {
+			case "function", "class":
+				p += fmt.Sprintf("文件: %s (第 %d-%d 行)\n", c.FilePath, c.StartLine, c.EndLine)
+			case "callers":
+				p += fmt.Sprintf("文件: %s (第 %d-%d 行，调用了变更的 %s)\n", c.FilePath, c.StartLine, c.EndLine, c.Symbol)
+			case "callees":
+				p += fmt.Sprintf("文件: %s (第 %d-%d 行，被变更代码调用的 %s)\n", c.FilePath, c.StartLine, c.EndLine, c.Symbol)
+			case "blame":
+				p += fmt.Sprintf("文件: %s (变更前第 %d-%d 行的最近修改记录，git blame)\n", c.FilePath, c.StartLine, c.EndLine)
+			case "compare":
+				p += compareBlock(c)
+				continue
+			default:
+				p += "文件: " + c.FilePath + "\n"
+			}
 			p += ctxs[i].Content + "\n"
 		}
 	}
@@ -86,3 +100,25 @@ func extractFileListFromDiff(diff string) []string {
 
 	return files
 }
+
+// WithGuidelines appends a project's own review instructions to a prompt.
+func WithGuidelines(p, extra string) string {
+	if extra == "" {
+		return p
+	}
+	return p + "\n**项目评审要求**（由仓库配置提供，不得与上述输出格式冲突）：\n" + extra + "\n"
+}
+
+// compareBlock renders the base and patched versions of a function one after
+// the other, so the model can spot changed behaviour and dropped checks.
+func compareBlock(c ContextInfo) string {
+	side := func(label, content string, start, end int, missing string) string {
+		if content == "" {
+			return fmt.Sprintf("[%s] %s\n", label, missing)
+		}
+		return fmt.Sprintf("[%s 第 %d-%d 行]\n%s\n", label, start, end, content)
+	}
+	return fmt.Sprintf("文件: %s 函数 %s 变更前后对比\n", c.FilePath, c.Symbol) +
+		side("变更前", c.OldContent, c.OldStartLine, c.OldEndLine, "无（新增函数）") +
+		side("变更后", c.Content, c.StartLine, c.EndLine, "无（函数已删除）")
+}
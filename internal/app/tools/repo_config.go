@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"eino-gerrit-review/internal/config"
+	"errors"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// PatchsetLevel is Gerrit's file name for comments on the patch set as a whole.
+const PatchsetLevel = "/PATCHSET_LEVEL"
+
+// RepoConfigRule is the rule ID of the notice posted for a broken in-repo config.
+const RepoConfigRule = "repo-config"
+
+// ChangeTarget returns the project and branch of a change, looking them up
+// when project is empty. A failed lookup only costs the project-specific
+// profile and mirror, so it is logged and the given values are returned.
+func (t *GerritTool) ChangeTarget(changeNum, project, branch string) (string, string) {
+	if project != "" {
+		return project, branch
+	}
+	c, err := t.GetChange(changeNum)
+	if err != nil {
+		g.Log().Warningf(context.Background(), "change %s: project lookup failed, using the default rule profile: %v", changeNum, err)
+		return project, branch
+	}
+	return c.Project, c.Branch
+}
+
+// ReviewProfile picks the rule profile for a change: the server-side profile for
+// its project and branch, as returned by ChangeTarget, with the repository's
+// .ai-review file merged on top. An in-repo file that fails to parse or
+// validate comes back as a single patch-set-level notice and the server-side
+// profile is used. Transient Gerrit failures are returned as errors, so the
+// run can be retried; other read failures are only logged.
+func (t *GerritTool) ReviewProfile(changeNum, patchset, project, branch string) (config.RuleProfile, []RuleAdvice, error) {
+	prof := config.RuleProfileFor(project, branch)
+	name, b, err := t.repoConfig(changeNum, patchset)
+	if err != nil {
+		if ErrorClass(err) != "" {
+			return prof, nil, err
+		}
+		// E.g. no read access: there may be no in-repo file at all, so
+		// nothing is posted on the change.
+		g.Log().Warningf(context.Background(), "change %s: reading %s failed, using the server rule profile: %v", changeNum, name, err)
+		return prof, nil, nil
+	}
+	if b == nil {
+		return prof, nil, nil
+	}
+	merged, err := config.ApplyRepoConfig(prof, name, b)
+	if err != nil {
+		return prof, []RuleAdvice{repoConfigNotice(name, err)}, nil
+	}
+	return merged, nil, nil
+}
+
+// repoConfig reads the first in-repo config file that exists. It reads the base
+// revision, i.e. the target branch, so a change cannot relax its own review.
+func (t *GerritTool) repoConfig(changeNum, patchset string) (string, []byte, error) {
+	for _, name := range config.RepoConfigFiles {
+		s, err := t.GetFileContentFromParent(changeNum, patchset, name)
+		if errors.Is(err, ErrNotFound) || (err == nil && s == "") {
+			continue
+		}
+		if err != nil {
+			return name, nil, err
+		}
+		return name, []byte(s), nil
+	}
+	return "", nil, nil
+}
+
+func repoConfigNotice(name string, err error) RuleAdvice {
+	return RuleAdvice{
+		Rule:     RepoConfigRule,
+		Severity: "high",
+		Title:    "评审配置 " + name + " 未生效",
+		Detail:   err.Error(),
+		Suggest:  "修正该文件后重新触发评审；本次评审使用服务端默认配置",
+		File:     PatchsetLevel,
+	}
+}
+
+// DropIgnored removes diffs whose path the profile ignores.
+func DropIgnored(diffs []map[string]interface{}, prof config.RuleProfile) []map[string]interface{} {
+	if len(prof.Ignore) == 0 {
+		return diffs
+	}
+	out := make([]map[string]interface{}, 0, len(diffs))
+	for _, d := range diffs {
+		if p, _ := d["path"].(string); !prof.Ignored(p) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// FilterSeverity drops advice below the profile's severity threshold.
+func FilterSeverity(static []RuleAdvice, llm []LLMAdvice, prof config.RuleProfile) ([]RuleAdvice, []LLMAdvice) {
+	if prof.MinSeverity == "" {
+		return static, llm
+	}
+	var s []RuleAdvice
+	for _, a := range static {
+		if config.SeverityAtLeast(a.Severity, prof.MinSeverity) {
+			s = append(s, a)
+		}
+	}
+	var l []LLMAdvice
+	for _, a := range llm {
+		if config.SeverityAtLeast(a.Severity, prof.MinSeverity) {
+			l = append(l, a)
+		}
+	}
+	return s, l
+}
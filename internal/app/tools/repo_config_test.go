@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"encoding/base64"
+	"eino-gerrit-review/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func repoConfigServer(t *testing.T, files map[string]string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/a/changes/7":
+			w.Write([]byte(")]}'\n" + `{"_number": 7, "project": "kernel/common", "branch": "main"}`))
+		case strings.HasSuffix(r.URL.Path, "/content") && r.URL.Query().Get("parent") == "1":
+			name := strings.TrimSuffix(r.URL.Path[strings.Index(r.URL.Path, "/files/")+len("/files/"):], "/content")
+			body, ok := files[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(body))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("GERRIT_BASE_URL", srv.URL)
+}
+
+func TestReviewProfileMergesRepoConfig(t *testing.T) {
+	config.SetRuleProfiles([]config.RuleProfile{{Name: "kernel", Projects: []string{"kernel/*"}, Switches: config.RuleSwitches{LinuxSpinSleep: true}}})
+	defer config.SetRuleProfiles(nil)
+	repoConfigServer(t, map[string]string{".ai-review.yml": "minSeverity: high\nignore: [\"*.md\"]\n"})
+
+	gt := &GerritTool{}
+	project, branch := gt.ChangeTarget("7", "", "")
+	if project != "kernel/common" || branch != "main" {
+		t.Fatalf("change target %q %q", project, branch)
+	}
+	prof, notices, err := gt.ReviewProfile("7", "1", project, branch)
+	if err != nil || len(notices) != 0 {
+		t.Fatalf("unexpected result: %v %+v", err, notices)
+	}
+	if prof.Name != "kernel" || prof.RepoConfig != ".ai-review.yml" || prof.MinSeverity != "high" || !prof.Switches.LinuxSpinSleep {
+		t.Fatalf("unexpected profile: %+v", prof)
+	}
+	diffs := DropIgnored([]map[string]interface{}{{"path": "README.md"}, {"path": "lock.c"}}, prof)
+	if len(diffs) != 1 || diffs[0]["path"] != "lock.c" {
+		t.Fatalf("ignored file kept: %+v", diffs)
+	}
+	static, llm := FilterSeverity([]RuleAdvice{{Severity: "high"}, {Severity: "low"}}, []LLMAdvice{{Severity: "medium"}}, prof)
+	if len(static) != 1 || len(llm) != 0 {
+		t.Fatalf("threshold not applied: %+v %+v", static, llm)
+	}
+}
+
+func TestBrokenRepoConfigBecomesOneComment(t *testing.T) {
+	repoConfigServer(t, map[string]string{".ai-review.json": `{"minSeverity": "urgent"}`})
+
+	prof, notices, err := (&GerritTool{}).ReviewProfile("7", "1", "", "")
+	if err != nil || prof.RepoConfig != "" || len(notices) != 1 {
+		t.Fatalf("unexpected result: %+v %+v %v", prof, notices, err)
+	}
+	n := notices[0]
+	if n.File != PatchsetLevel || n.Rule != RepoConfigRule || !strings.Contains(n.Title, ".ai-review.json") || !strings.Contains(n.Detail, "minSeverity") {
+		t.Fatalf("unexpected notice: %+v", n)
+	}
+	out := FormatForGerrit(Synthesize(notices, nil))
+	c := out["comments"].(map[string][]map[string]interface{})[PatchsetLevel]
+	if len(c) != 1 {
+		t.Fatalf("expected one patch-set comment: %+v", out)
+	}
+	if _, ok := c[0]["line"]; ok {
+		t.Fatalf("patch-set comments must not carry a line: %+v", c[0])
+	}
+}
+
+func TestUnreadableRepoConfigIsNotPosted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	t.Setenv("GERRIT_BASE_URL", srv.URL)
+
+	prof, notices, err := (&GerritTool{}).ReviewProfile("7", "1", "kernel/common", "main")
+	if err != nil || len(notices) != 0 || prof.RepoConfig != "" {
+		t.Fatalf("unexpected result: %+v %+v %v", prof, notices, err)
+	}
+}
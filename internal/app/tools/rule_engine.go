@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/config"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Rule is a static check run against the added lines of a changed file.
+// Register implementations with RegisterRule; StaticRuleTool runs every
+// registered rule whose languages and path globs match the file.
+type Rule interface {
+	ID() string
+	// Languages lists detectLangByPath values the rule applies to; empty means all.
+	Languages() []string
+	// PathGlobs restricts the rule to matching paths; empty means all. See config.MatchGlob.
+	PathGlobs() []string
+	Severity() string
+	Check(f *RuleFile) []Finding
+}
+
+// Finding is one problem reported by a rule. Line is a new-file line number.
+type Finding struct {
+	Line    int
+	Title   string
+	Detail  string
+	Suggest string
+	// Severity overrides the rule's default when set.
+	Severity string
+}
+
+// RuleFile is a changed file as rules see it: the added lines to judge and
+// whatever is known about the new file for scope checks.
+type RuleFile struct {
+	Path  string
+	Lang  string
+	Added []Line
+	// Content is the whole new file when file context was fetched, otherwise
+	// the added lines joined.
+	Content string
+	// Length is the new file's line count, or a lower bound without context.
+	Length int
+	Config config.RuleSwitches
+
+	// newSide maps new-file line numbers to text, from the diff and, when
+	// file-level context was fetched, from the whole file.
+	newSide map[int]string
+	funcs   []Symbol
+	funcsOK bool
+}
+
+func newRuleFile(fd FileDiff, full *ContextInfo, cfg config.RuleSwitches) *RuleFile {
+	f := &RuleFile{Path: fd.Path, Lang: detectLangByPath(fd.Path), Added: fd.AddedLines(), Config: cfg, newSide: make(map[int]string)}
+	for _, h := range fd.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind != LineDeleted && l.New > 0 {
+				f.newSide[l.New] = l.Text
+				if l.New > f.Length {
+					f.Length = l.New
+				}
+			}
+		}
+	}
+	if full != nil {
+		lines := strings.Split(full.Content, "\n")
+		for i, s := range lines {
+			f.newSide[full.StartLine+i] = s
+		}
+		f.Content = full.Content
+		if n := full.StartLine + len(lines) - 1; n > f.Length {
+			f.Length = n
+		}
+		return f
+	}
+	var b strings.Builder
+	for _, l := range f.Added {
+		b.WriteString(l.Text + "\n")
+	}
+	f.Content = b.String()
+	return f
+}
+
+// Above returns the known new-file lines before line n in order, followed by the
+// text of line n up to column col.
+func (f *RuleFile) Above(n, col int) []string {
+	nums := make([]int, 0, len(f.newSide))
+	for k := range f.newSide {
+		if k < n {
+			nums = append(nums, k)
+		}
+	}
+	sort.Ints(nums)
+	out := make([]string, 0, len(nums)+1)
+	for _, k := range nums {
+		out = append(out, f.newSide[k])
+	}
+	if s, ok := f.newSide[n]; ok && col <= len(s) {
+		out = append(out, s[:col])
+	}
+	return out
+}
+
+// Functions returns the functions the file's language adapter finds in the known
+// new-file lines. Unknown lines read as blank, so without file context only
+// functions inside the diff hunks are found; see Complete.
+func (f *RuleFile) Functions() []Symbol {
+	if !f.funcsOK {
+		lines := make([]string, f.Length)
+		for n, s := range f.newSide {
+			if n >= 1 && n <= f.Length {
+				lines[n-1] = s
+			}
+		}
+		f.funcs = Functions(adapterForPath(f.Path).Outline(strings.Join(lines, "\n")))
+		f.funcsOK = true
+	}
+	return f.funcs
+}
+
+// Complete reports whether every new-file line from start to end is known.
+func (f *RuleFile) Complete(start, end int) bool {
+	for n := start; n <= end; n++ {
+		if _, ok := f.newSide[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// inWhitelistedFunction reports whether line n lies in a function named by the
+// function whitelists, either plainly or as "Class.name".
+func (f *RuleFile) inWhitelistedFunction(n int) bool {
+	names := append(append([]string(nil), f.Config.WhiteListFunctions...), f.Config.WhiteListFunctionsByLang[f.Lang]...)
+	if len(names) == 0 {
+		return false
+	}
+	for _, fn := range f.Functions() {
+		if n < fn.Start || n > fn.End {
+			continue
+		}
+		for _, w := range names {
+			if w != "" && (w == fn.Name || w == fn.QualifiedName()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	ruleMu   sync.RWMutex
+	ruleList []Rule
+)
+
+// RegisterRule adds r to the registry, replacing any rule with the same ID.
+func RegisterRule(r Rule) {
+	ruleMu.Lock()
+	defer ruleMu.Unlock()
+	for i, x := range ruleList {
+		if x.ID() == r.ID() {
+			ruleList[i] = r
+			return
+		}
+	}
+	ruleList = append(ruleList, r)
+}
+
+// UnregisterRule removes the rule with the given ID.
+func UnregisterRule(id string) {
+	ruleMu.Lock()
+	defer ruleMu.Unlock()
+	for i, x := range ruleList {
+		if x.ID() == id {
+			ruleList = append(ruleList[:i:i], ruleList[i+1:]...)
+			return
+		}
+	}
+}
+
+// Rules returns the registered rules in registration order.
+func Rules() []Rule {
+	ruleMu.RLock()
+	defer ruleMu.RUnlock()
+	return append([]Rule(nil), ruleList...)
+}
+
+func ruleApplies(r Rule, f *RuleFile) bool {
+	if langs := r.Languages(); len(langs) > 0 {
+		ok := false
+		for _, l := range langs {
+			if l == f.Lang {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	globs := r.PathGlobs()
+	if len(globs) == 0 {
+		return true
+	}
+	for _, g := range globs {
+		if config.MatchGlob(g, f.Path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/config"
+	"strings"
+	"testing"
+)
+
+type todoRule struct{}
+
+func (todoRule) ID() string          { return "test-todo" }
+func (todoRule) Languages() []string { return []string{"c"} }
+func (todoRule) PathGlobs() []string { return []string{"src/**"} }
+func (todoRule) Severity() string    { return "low" }
+func (todoRule) Check(f *RuleFile) []Finding {
+	var out []Finding
+	for _, l := range f.Added {
+		if strings.Contains(l.Text, "TODO") {
+			out = append(out, Finding{Line: l.New, Title: "TODO"})
+		}
+	}
+	return out
+}
+
+func TestRegisteredRuleRuns(t *testing.T) {
+	config.SetRuleSwitches(config.RuleSwitches{})
+	RegisterRule(todoRule{})
+	defer UnregisterRule("test-todo")
+
+	diffs := []map[string]interface{}{
+		mockFileDiff("src/a.c", "int a;", "// TODO fix").ToMap(),
+		mockFileDiff("lib/b.c", "// TODO elsewhere").ToMap(),
+		mockFileDiff("src/c.java", "// TODO wrong language").ToMap(),
+	}
+	adv := (&StaticRuleTool{}).Run(diffs, nil)
+	if len(adv) != 1 || adv[0].Rule != "test-todo" || adv[0].File != "src/a.c" || adv[0].Line != 2 || adv[0].Severity != "low" {
+		t.Fatalf("unexpected advice: %+v", adv)
+	}
+}
+
+func TestUISleepRuleNeedsUIClass(t *testing.T) {
+	config.SetRuleSwitches(config.RuleSwitches{AndroidUiSleep: true})
+	diffs := []map[string]interface{}{
+		mockFileDiff("app/src/main/java/com/x/SyncWorker.java", "Thread.sleep(100);").ToMap(),
+		mockFileDiff("app/src/main/java/com/x/LoginActivity.java", "Thread.sleep(100);").ToMap(),
+	}
+	adv := (&StaticRuleTool{}).Run(diffs, nil)
+	if len(adv) != 1 || adv[0].Rule != "android-ui-sleep" || !strings.HasSuffix(adv[0].File, "LoginActivity.java") {
+		t.Fatalf("unexpected advice: %+v", adv)
+	}
+}
+
+func TestProfileDisablesBuiltinRule(t *testing.T) {
+	diffs := []map[string]interface{}{
+		mockFileDiff("drivers/lock.c", "spin_lock(&l);", "msleep(1);", "spin_unlock(&l);").ToMap(),
+	}
+	prof := config.RuleProfile{Name: "quiet", Switches: config.RuleSwitches{LinuxSpinSleep: true}}
+	if adv := (&StaticRuleTool{Profile: &prof}).Run(diffs, nil); len(adv) != 1 {
+		t.Fatalf("expected spin sleep advice, got %+v", adv)
+	}
+	prof.DisabledRules = []string{"linux-spin-sleep"}
+	if adv := (&StaticRuleTool{Profile: &prof}).Run(diffs, nil); len(adv) != 0 {
+		t.Fatalf("disabled rule still ran: %+v", adv)
+	}
+}
+
+func TestFunctionTooLongRule(t *testing.T) {
+	body := make([]string, 0, 12)
+	for i := 0; i < 8; i++ {
+		body = append(body, "\tx++;")
+	}
+	file := "void small(void) {\n}\n\nvoid big(void)\n{\n" + strings.Join(body, "\n") + "\n}\n\nvoid quiet(void) {\n" + strings.Join(body, "\n") + "\n}\n"
+	// The change touches big and quiet; only the functions' whole bodies come from context.
+	fd := FileDiff{Path: "drivers/x.c", Hunks: []Hunk{
+		{OldStart: 6, NewStart: 6, Lines: []Line{{Kind: LineAdded, New: 6, Text: "\tx++;"}}},
+		{OldStart: 17, NewStart: 17, Lines: []Line{{Kind: LineAdded, New: 17, Text: "\tx++;"}}},
+	}}
+	diffs := []map[string]interface{}{fd.ToMap()}
+	ctxs := []ContextInfo{{FilePath: "drivers/x.c", Content: file, StartLine: 1, ContextType: "file"}}
+	cfg := config.RuleSwitches{FunctionTooLong: true, FunctionLengthLimit: 5, WhiteListFunctions: []string{"quiet"}}
+	prof := config.RuleProfile{Switches: cfg}
+
+	adv := (&StaticRuleTool{Profile: &prof}).Run(diffs, ctxs)
+	if len(adv) != 1 || adv[0].Rule != "function-too-long" || adv[0].Line != 6 || !strings.Contains(adv[0].Detail, "big 共 11 行") {
+		t.Fatalf("expected one finding for big: %+v", adv)
+	}
+	if adv := (&StaticRuleTool{Profile: &prof}).Run(diffs, nil); len(adv) != 0 {
+		t.Fatalf("function length is unknown without context: %+v", adv)
+	}
+	prof.Switches.FunctionLengthLimitByLang = map[string]int{"c": 20}
+	if adv := (&StaticRuleTool{Profile: &prof}).Run(diffs, ctxs); len(adv) != 0 {
+		t.Fatalf("language limit should apply: %+v", adv)
+	}
+	prof.Switches.PathFunctionLengthLimit = map[string]int{"drivers/**": 8}
+	if adv := (&StaticRuleTool{Profile: &prof}).Run(diffs, ctxs); len(adv) != 1 {
+		t.Fatalf("path limit should win over the language one: %+v", adv)
+	}
+}
+
+func TestFileAndFunctionLimitsDiffer(t *testing.T) {
+	body := make([]string, 0, 30)
+	for i := 0; i < 30; i++ {
+		body = append(body, "\t\tx++;")
+	}
+	src := append(append([]string{"class A {", "\tvoid run() {"}, body...), "\t}", "}")
+	diffs := []map[string]interface{}{mockFileDiff("src/A.java", src...).ToMap()}
+	run := func(cfg config.RuleSwitches) []RuleAdvice {
+		cfg.FileTooLong, cfg.FunctionTooLong = true, true
+		prof := config.RuleProfile{Switches: cfg}
+		return (&StaticRuleTool{Profile: &prof}).Run(diffs, nil)
+	}
+	rules := func(adv []RuleAdvice) string {
+		var ids []string
+		for _, a := range adv {
+			ids = append(ids, a.Rule)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// The 34-line file is within its limit; the 32-line function is not.
+	if got := rules(run(config.RuleSwitches{FunctionLengthLimit: 20, LengthLimitByLang: map[string]int{"java": 100}})); got != "function-too-long" {
+		t.Fatalf("function limit tighter than file limit: %s", got)
+	}
+	// A file limit must not be applied to functions.
+	if got := rules(run(config.RuleSwitches{FunctionLengthLimit: 200, LengthLimitByLang: map[string]int{"java": 30}, PathLengthLimit: map[string]int{"src/": 30}})); got != "file-too-long" {
+		t.Fatalf("file limit leaked into the function check: %s", got)
+	}
+	if got := rules(run(config.RuleSwitches{FunctionLengthLimit: 200, LengthLimitByLang: map[string]int{"java": 100}, FunctionLengthLimitByLang: map[string]int{"java": 10}})); got != "function-too-long" {
+		t.Fatalf("per-language function limit: %s", got)
+	}
+	if got := rules(run(config.RuleSwitches{FunctionLengthLimit: 200, LengthLimitByLang: map[string]int{"java": 100}, PathFunctionLengthLimit: map[string]int{"src/": 10}})); got != "function-too-long" {
+		t.Fatalf("per-path function limit: %s", got)
+	}
+}
+
+func TestFunctionWhitelistIsPerFunction(t *testing.T) {
+	src := []string{"class MainActivity {", "  void onCreate() {", "    Thread.sleep(1);", "  }", "  void onResume() {", "    Thread.sleep(1);", "  }", "}"}
+	prof := config.RuleProfile{Switches: config.RuleSwitches{AndroidUiSleep: true, WhiteListFunctions: []string{"MainActivity.onCreate"}}}
+	adv := (&StaticRuleTool{Profile: &prof}).Run([]map[string]interface{}{mockFileDiff("app/MainActivity.java", src...).ToMap()}, nil)
+	if len(adv) != 1 || adv[0].Line != 6 {
+		t.Fatalf("only onResume should be flagged: %+v", adv)
+	}
+}
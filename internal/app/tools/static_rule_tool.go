@@ -6,6 +6,7 @@ import (
 )
 
 type RuleAdvice struct {
+	Rule     string
 	Severity string
 	Title    string
 	Detail   string
@@ -14,88 +15,105 @@ type RuleAdvice struct {
 	Line     int
 }
 
-type StaticRuleTool struct{}
+type StaticRuleTool struct {
+	// Profile selects the switches and declarative rules to apply; nil uses
+	// the default profile.
+	Profile *config.RuleProfile
+	// Suppressed counts the findings the last Run dropped for ai-review:ignore markers.
+	Suppressed int
+}
+
+// fileContexts indexes whole-file contexts by path. Function, class and dependency
+// contexts are excerpts whose line numbers do not match the new file.
+func fileContexts(ctxs []ContextInfo) map[string]*ContextInfo {
+	out := make(map[string]*ContextInfo, len(ctxs))
+	for _, c := range ctxs {
+		if c.ContextType == "file" || c.ContextType == "" {
+			c := c
+			if c.StartLine <= 0 {
+				c.StartLine = 1
+			}
+			out[c.FilePath] = &c
+		}
+	}
+	return out
+}
 
+// Run checks the added lines of every diff with the registered rules. Context
+// fetched for a file only widens what the rules can see around those lines;
+// untouched code is never flagged.
 func (t *StaticRuleTool) Run(diffs []map[string]interface{}, ctxs []ContextInfo) []RuleAdvice {
 	var out []RuleAdvice
-	cfg := config.GetRuleSwitches()
+	prof := config.RuleProfileFor("", "")
+	if t.Profile != nil {
+		prof = *t.Profile
+	}
+	cfg := prof.Switches
+	full := fileContexts(ctxs)
+	rules := activeRules(prof)
 
-	for _, c := range ctxs {
-		if shouldSkip(c, cfg) {
+	t.Suppressed = 0
+	for _, d := range diffs {
+		fd := diffFile(d)
+		f := newRuleFile(fd, full[fd.Path], cfg)
+		if len(f.Added) == 0 {
 			continue
 		}
-
-		// Linux Kernel Rules
-		if c.FilePath == "kernel/lock.c" {
-			if cfg.LinuxSpinSleep && strings.Contains(c.Content, "spin_lock") && strings.Contains(c.Content, "msleep") {
-				out = append(out, RuleAdvice{
-					Severity: "high",
-					Title:    "自旋锁内睡眠",
-					Detail:   "spin_lock 区间包含 msleep 可能导致死锁或调度问题",
-					Suggest:  "避免在自旋锁持有期间睡眠，改用合适的同步原语或重构逻辑",
-					File:     c.FilePath,
-					Line:     findLine(c.Content, "msleep"),
-				})
-			}
+		if shouldSkip(ContextInfo{FilePath: f.Path, Content: f.Content}, cfg) {
+			continue
 		}
-
-		// Android Rules
-		if c.FilePath == "app/src/main/java/com/example/MainActivity.java" {
-			if cfg.AndroidUiSleep && strings.Contains(c.Content, "Thread.sleep") {
-				out = append(out, RuleAdvice{
-					Severity: "high",
-					Title:    "主线程阻塞",
-					Detail:   "MainActivity 中调用 Thread.sleep 阻塞 UI 线程",
-					Suggest:  "在后台线程执行耗时操作或使用 Handler/Post 延迟",
-					File:     c.FilePath,
-					Line:     findLine(c.Content, "Thread.sleep"),
-				})
+		for _, r := range rules {
+			if !ruleApplies(r, f) {
+				continue
 			}
-			if cfg.AndroidWebView && strings.Contains(c.Content, "WebView") && !strings.Contains(c.Content, "setJavaScriptEnabled(false)") {
+			for _, fn := range r.Check(f) {
+				if f.Suppressed(r.ID(), fn.Line) {
+					t.Suppressed++
+					continue
+				}
+				if f.inWhitelistedFunction(fn.Line) {
+					continue
+				}
+				sev := fn.Severity
+				if sev == "" {
+					sev = r.Severity()
+				}
 				out = append(out, RuleAdvice{
-					Severity: "medium",
-					Title:    "WebView 安全设置缺失",
-					Detail:   "未显式关闭或管控 JavaScript，可能存在风险",
-					Suggest:  "根据业务需要配置 WebSettings 并限制敏感能力",
-					File:     c.FilePath,
-					Line:     1,
+					Rule:     r.ID(),
+					Severity: sev,
+					Title:    fn.Title,
+					Detail:   fn.Detail,
+					Suggest:  fn.Suggest,
+					File:     f.Path,
+					Line:     fn.Line,
 				})
 			}
 		}
-
-		// General Rules
-		lines := strings.Count(c.Content, "\n") + 1
-		limit := cfg.FunctionLengthLimit
-		if v, ok := cfg.LengthLimitByLang[detectLangByPath(c.FilePath)]; ok && v > 0 {
-			limit = v
-		}
-		for p, v := range cfg.PathLengthLimit {
-			if v > 0 && strings.Contains(c.FilePath, p) {
-				limit = v
-			}
-		}
-
-		if cfg.FileTooLong && lines > limit {
-			out = append(out, RuleAdvice{
-				Severity: "medium",
-				Title:    "文件过长",
-				Detail:   "上下文内容超过限制，建议拆分以提升可维护性",
-				Suggest:  "重构为更小的模块或函数",
-				File:     c.FilePath,
-				Line:     1,
-			})
-		}
 	}
 	return out
 }
 
+// diffFile returns the typed diff of a diff map, parsing the rendered patch
+// for maps built without one.
+func diffFile(d map[string]interface{}) FileDiff {
+	if fd, ok := DiffOf(d); ok {
+		return fd
+	}
+	p, _ := d["path"].(string)
+	patch, _ := d["patch"].(string)
+	return ParsePatch(p, patch)
+}
+
 func shouldSkip(c ContextInfo, cfg config.RuleSwitches) bool {
 	for _, w := range cfg.WhiteListFiles {
-		if strings.Contains(c.FilePath, w) {
+		if w != "" && config.MatchPath(w, c.FilePath) {
 			return true
 		}
 	}
-	if len(cfg.WhiteListFunctions) > 0 {
+	// Languages whose adapter finds functions honour the function whitelists
+	// per function instead, see RuleFile.inWhitelistedFunction.
+	funcAware := functionAware(c.FilePath)
+	if len(cfg.WhiteListFunctions) > 0 && !funcAware {
 		for _, fn := range cfg.WhiteListFunctions {
 			if fn != "" && strings.Contains(c.Content, fn) {
 				return true
@@ -106,13 +124,13 @@ func shouldSkip(c ContextInfo, cfg config.RuleSwitches) bool {
 		lang := detectLangByPath(c.FilePath)
 		if arr, ok := cfg.WhiteListFilesByLang[lang]; ok {
 			for _, w := range arr {
-				if w != "" && strings.Contains(c.FilePath, w) {
+				if w != "" && config.MatchPath(w, c.FilePath) {
 					return true
 				}
 			}
 		}
 	}
-	if len(cfg.WhiteListFunctionsByLang) > 0 {
+	if len(cfg.WhiteListFunctionsByLang) > 0 && !funcAware {
 		lang := detectLangByPath(c.FilePath)
 		if arr, ok := cfg.WhiteListFunctionsByLang[lang]; ok {
 			for _, fn := range arr {
@@ -125,12 +143,9 @@ func shouldSkip(c ContextInfo, cfg config.RuleSwitches) bool {
 	return false
 }
 
-func findLine(s, sub string) int {
-	idx := strings.Index(s, sub)
-	if idx < 0 {
-		return 1
-	}
-	return strings.Count(s[:idx], "\n") + 1
+func functionAware(p string) bool {
+	_, ok := adapterForPath(p).(DefaultAdapter)
+	return !ok
 }
 
 func detectLangByPath(p string) string {
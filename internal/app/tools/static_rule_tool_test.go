@@ -1,13 +1,86 @@
 package tools
 
 import (
+    "os"
+    "strconv"
+    "strings"
     "testing"
     "eino-gerrit-review/internal/config"
 )
 
 func TestStaticRules(t *testing.T) {
     config.SetRuleSwitches(config.RuleSwitches{LinuxSpinSleep: true, AndroidUiSleep: true, AndroidWebView: true, FileTooLong: true})
-    ctxs := []ContextInfo{{FilePath: "kernel/lock.c", Content: "spin_lock(&l); msleep(1); spin_unlock(&l)", ContextType: "file"}}
-    adv := (&StaticRuleTool{}).Run(nil, ctxs)
-    if len(adv) == 0 { t.Fatalf("expected advice") }
+    diffs := []map[string]interface{}{mockFileDiff("kernel/lock.c", "int x;", "spin_lock(&l); msleep(1); spin_unlock(&l)").ToMap()}
+    adv := (&StaticRuleTool{}).Run(diffs, nil)
+    if len(adv) != 1 || adv[0].Line != 2 { t.Fatalf("expected advice on line 2, got %+v", adv) }
+}
+
+func TestStaticRulesIgnoreUntouchedCode(t *testing.T) {
+    config.SetRuleSwitches(config.RuleSwitches{LinuxSpinSleep: true})
+    file := "spin_lock(&l);\nmsleep(1);\nspin_unlock(&l);\nint a;\nint b;"
+    ctxs := []ContextInfo{{FilePath: "drivers/x.c", Content: file, ContextType: "file", StartLine: 1}}
+    fd := FileDiff{Path: "drivers/x.c", Hunks: []Hunk{{OldStart: 5, NewStart: 5, Lines: []Line{{Kind: LineAdded, New: 5, Text: "int b;"}}}}}
+    if adv := (&StaticRuleTool{}).Run([]map[string]interface{}{fd.ToMap()}, ctxs); len(adv) != 0 {
+        t.Fatalf("old code must not be flagged: %+v", adv)
+    }
+}
+
+func TestStaticRulesUseContextForScope(t *testing.T) {
+    config.SetRuleSwitches(config.RuleSwitches{LinuxSpinSleep: true})
+    fd := FileDiff{Path: "drivers/x.c", Hunks: []Hunk{{OldStart: 3, NewStart: 3, Lines: []Line{{Kind: LineAdded, New: 3, Text: "\tmsleep(10);"}}}}}
+    diffs := []map[string]interface{}{fd.ToMap()}
+    if adv := (&StaticRuleTool{}).Run(diffs, nil); len(adv) != 0 {
+        t.Fatalf("no lock is visible without context: %+v", adv)
+    }
+    ctxs := []ContextInfo{{FilePath: "drivers/x.c", Content: "void f(void) {\n\tspin_lock_irqsave(&l, flags);\n\tmsleep(10);\n\tspin_unlock_irqrestore(&l, flags);\n}", ContextType: "file", StartLine: 1}}
+    adv := (&StaticRuleTool{}).Run(diffs, ctxs)
+    if len(adv) != 1 || adv[0].Line != 3 || adv[0].File != "drivers/x.c" {
+        t.Fatalf("expected advice on line 3, got %+v", adv)
+    }
+}
+
+func TestStaticRulesOnLegacyPatch(t *testing.T) {
+    config.SetRuleSwitches(config.RuleSwitches{AndroidUiSleep: true})
+    diffs := []map[string]interface{}{{"path": "app/ui/HomeFragment.kt", "patch": "  [L7] fun load() {\n+ [L8]     Thread.sleep(50)\n- old()\n"}}
+    adv := (&StaticRuleTool{}).Run(diffs, nil)
+    if len(adv) != 1 || adv[0].Line != 8 {
+        t.Fatalf("expected advice on line 8, got %+v", adv)
+    }
+}
+
+func TestExampleRulesPathMatching(t *testing.T) {
+    b, err := os.ReadFile("../../config/examples/rules.json")
+    if err != nil {
+        t.Fatal(err)
+    }
+    cfg, _, err := config.ParseRuleConfig(b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    cases := []struct {
+        path  string
+        skip  bool
+        limit int
+    }{
+        {"external/third_party/zlib/inflate.c", true, 1000},
+        {"src/generated/proto.c", true, 1000},
+        {"src/nongenerated/proto.c", false, 1000},
+        {"generated/top.c", true, 1000},
+        {"app/src/main/java/com/x/Generated.java", true, 120},
+        // A name without "/" still matches as a substring.
+        {"app/src/main/java/com/x/NotGenerated.java", true, 120},
+        {"app/src/main/java/com/x/Main.java", false, 120},
+        {"lib/src/Main.java", false, 1000},
+        {"drivers/net/eth.go", false, 200},
+    }
+    for _, c := range cases {
+        if got := shouldSkip(ContextInfo{FilePath: c.path}, cfg); got != c.skip {
+            t.Fatalf("shouldSkip(%q) = %v, want %v", c.path, got, c.skip)
+        }
+        f := &RuleFile{Path: c.path, Lang: detectLangByPath(c.path), Added: []Line{{Kind: LineAdded, New: 1}}, Length: 5000, Config: cfg}
+        adv := fileTooLongRule{}.Check(f)
+        if len(adv) != 1 || !strings.Contains(adv[0].Detail, strconv.Itoa(c.limit)+" 行") {
+            t.Fatalf("%s: want limit %d, got %+v", c.path, c.limit, adv)
+        }
+    }
 }
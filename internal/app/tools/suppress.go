@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/config"
+	"regexp"
+	"strings"
+)
+
+// LLMRule is the rule ID suppression markers use for model findings.
+const LLMRule = "llm"
+
+// suppressRe matches "ai-review:ignore <rule-id> [reason]" inside a line or
+// block comment. The rule ID is required; "*" silences every rule.
+var suppressRe = regexp.MustCompile(`(?://|#|/\*|<!--|--)\s*ai-review:ignore[ \t]+([\w.*-]+)`)
+
+// commentOnlyRe matches lines holding nothing but a comment.
+var commentOnlyRe = regexp.MustCompile(`^\s*(?://|#|/\*|\*|<!--|--)`)
+
+// markerFor reports whether s carries a suppression marker for rule.
+func markerFor(s, rule string) bool {
+	for _, m := range suppressRe.FindAllStringSubmatch(s, -1) {
+		if m[1] == "*" || m[1] == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Suppressed reports whether a finding of rule at new-file line n is silenced
+// by an ai-review:ignore marker: on the line itself, on a comment line directly
+// above it, or in the same places for the header of an enclosing brace block.
+// A finding without a line (n < 1) is never suppressed.
+func (f *RuleFile) Suppressed(rule string, n int) bool {
+	if n < 1 {
+		return false
+	}
+	if f.markedAt(rule, n) {
+		return true
+	}
+	for _, h := range f.enclosingHeaders(n) {
+		if f.markedAt(rule, h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *RuleFile) markedAt(rule string, n int) bool {
+	if markerFor(f.newSide[n], rule) {
+		return true
+	}
+	above, ok := f.newSide[n-1]
+	return ok && commentOnlyRe.MatchString(above) && markerFor(above, rule)
+}
+
+// enclosingHeaders returns the header lines of the brace blocks still open
+// before line n, innermost last. A header is the line holding "{", or the
+// line of the previous token when the brace starts its line. Braces in
+// comments and literals do not count; files without an outline are lexed
+// as C.
+func (f *RuleFile) enclosingHeaders(n int) []int {
+	if n < 1 {
+		return nil
+	}
+	lines := make([]string, n-1)
+	for k, s := range f.newSide {
+		if k >= 1 && k < n {
+			lines[k-1] = s
+		}
+	}
+	fam, ok := familyFor(f.Path)
+	if !ok {
+		fam = familyC
+	}
+	var open []int
+	toks := tokenize(strings.Join(lines, "\n"), fam)
+	for i, t := range toks {
+		switch t.text {
+		case "{":
+			h := t.line
+			if t.first && i > 0 {
+				h = toks[i-1].line
+			}
+			open = append(open, h)
+		case "}":
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return open
+}
+
+// FilterSuppressedLLM drops model findings silenced by ai-review:ignore markers
+// for LLMRule or "*", and returns how many were dropped.
+func FilterSuppressedLLM(llm []LLMAdvice, diffs []map[string]interface{}, ctxs []ContextInfo) ([]LLMAdvice, int) {
+	if len(llm) == 0 {
+		return llm, 0
+	}
+	full := fileContexts(ctxs)
+	files := make(map[string]*RuleFile, len(diffs))
+	for _, d := range diffs {
+		fd := diffFile(d)
+		files[fd.Path] = newRuleFile(fd, full[fd.Path], config.RuleSwitches{})
+	}
+	out := make([]LLMAdvice, 0, len(llm))
+	n := 0
+	for _, a := range llm {
+		if f, ok := files[a.File]; ok && f.Suppressed(LLMRule, a.Line) {
+			n++
+			continue
+		}
+		out = append(out, a)
+	}
+	return out, n
+}
@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"eino-gerrit-review/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestSuppressionMarkers(t *testing.T) {
+	prof := config.RuleProfile{Switches: config.RuleSwitches{LinuxSpinSleep: true}}
+	cases := map[string]struct {
+		lines []string
+		want  int
+	}{
+		"same line":        {[]string{"spin_lock(&l);", "msleep(1); // ai-review:ignore linux-spin-sleep held for 1ms at boot", "spin_unlock(&l);"}, 0},
+		"line above":       {[]string{"spin_lock(&l);", "/* ai-review:ignore linux-spin-sleep boot only */", "msleep(1);", "spin_unlock(&l);"}, 0},
+		"enclosing block":  {[]string{"// ai-review:ignore * legacy driver", "static void probe(void)", "{", "\tspin_lock(&l);", "\tmsleep(1);", "\tspin_unlock(&l);", "}"}, 0},
+		"other rule":       {[]string{"spin_lock(&l);", "msleep(1); // ai-review:ignore file-too-long", "spin_unlock(&l);"}, 1},
+		"missing id":       {[]string{"spin_lock(&l);", "msleep(1); // ai-review:ignore", "spin_unlock(&l);"}, 1},
+		"in a string":      {[]string{"spin_lock(&l);", `msleep(1); puts("ai-review:ignore linux-spin-sleep");`, "spin_unlock(&l);"}, 1},
+		"brace in literal": {[]string{"// ai-review:ignore *", "static void probe(void)", "{", "\tputs(\"}\"); /* } */", "\tspin_lock(&l);", "\tmsleep(1);", "\tspin_unlock(&l);", "}"}, 0},
+		"brace in comment": {[]string{"void a(void) { // ai-review:ignore * {", "}", "void b(void) {", "spin_lock(&l);", "msleep(1);", "spin_unlock(&l); }"}, 1},
+		"block closed":     {[]string{"void a(void) { // ai-review:ignore *", "}", "void b(void) {", "spin_lock(&l);", "msleep(1);", "spin_unlock(&l); }"}, 1},
+	}
+	for name, c := range cases {
+		st := &StaticRuleTool{Profile: &prof}
+		adv := st.Run([]map[string]interface{}{mockFileDiff("drivers/x.c", c.lines...).ToMap()}, nil)
+		if len(adv) != c.want || st.Suppressed != 1-c.want {
+			t.Fatalf("%s: got %d advice, %d suppressed: %+v", name, len(adv), st.Suppressed, adv)
+		}
+	}
+}
+
+func TestFilterSuppressedLLM(t *testing.T) {
+	diffs := []map[string]interface{}{mockFileDiff("a.go", "x := 1", "y := 2 // ai-review:ignore llm intentional shadowing", "z := 3").ToMap()}
+	// The model may omit the line; such findings are kept.
+	llm := []LLMAdvice{{File: "a.go", Line: 2, Title: "shadowed"}, {File: "a.go", Line: 3}, {File: "b.go", Line: 2}, {File: "a.go", Line: 0}}
+	out, n := FilterSuppressedLLM(llm, diffs, nil)
+	if n != 1 || len(out) != 3 || out[0].Line != 3 || out[2].Line != 0 {
+		t.Fatalf("unexpected filter result: %d %+v", n, out)
+	}
+	msg, _ := FormatReview(Synthesize(nil, out), n)["message"].(string)
+	if !strings.Contains(msg, "1条已被") {
+		t.Fatalf("summary should count suppressed findings: %q", msg)
+	}
+}
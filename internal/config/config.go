@@ -11,6 +11,15 @@ type C struct {
     ModelMaxTokens int
     WorkerNum    int
     RateLimitQPS int
+    ReviewStorePath string
+    ReviewTTLHours  int
+    QueueSize       int
+    DrainTimeoutSec int
+    RetryMaxAttempts   int
+    RetryBaseDelayMs   int
+    RetryMaxDelayMs    int
+    RetryClassAttempts string
+    WatchConfigPath    string
 }
 
 func Load() *C {
@@ -23,6 +32,15 @@ func Load() *C {
         ModelMaxTokens: atoi(getenv("MODEL_MAX_TOKENS", "1024")),
         WorkerNum:      atoi(getenv("WORKER_NUM", "8")),
         RateLimitQPS:   atoi(getenv("RATE_LIMIT_QPS", "5")),
+        ReviewStorePath: os.Getenv("REVIEW_STORE_PATH"),
+        ReviewTTLHours:  atoi(getenv("REVIEW_TTL_HOURS", "168")),
+        QueueSize:       atoi(getenv("WORKER_QUEUE_SIZE", "64")),
+        DrainTimeoutSec: atoi(getenv("DRAIN_TIMEOUT_SECONDS", "120")),
+        RetryMaxAttempts:   atoi(getenv("RETRY_MAX_ATTEMPTS", "3")),
+        RetryBaseDelayMs:   atoi(getenv("RETRY_BASE_DELAY_MS", "2000")),
+        RetryMaxDelayMs:    atoi(getenv("RETRY_MAX_DELAY_MS", "60000")),
+        RetryClassAttempts: getenv("RETRY_CLASS_ATTEMPTS", "rate_limited=5"),
+        WatchConfigPath:    os.Getenv("WATCH_CONFIG_PATH"),
     }
 }
 
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var globCache, pathReCache sync.Map
+
+// MatchGlob matches a slash-separated path against a glob. "*" and "?" stay within
+// one path segment, "**" spans segments, and a pattern without "/" is matched
+// against the file name only, as in .gitignore.
+func MatchGlob(pattern, p string) bool {
+	if !strings.Contains(pattern, "/") {
+		p = path.Base(p)
+	}
+	return MatchFullGlob(pattern, p)
+}
+
+// MatchFullGlob is MatchGlob without the file-name shortcut, for names such as
+// Gerrit projects where "kernel" must not match "vendor/kernel".
+func MatchFullGlob(pattern, p string) bool {
+	re, ok := globCache.Load(pattern)
+	if !ok {
+		re, _ = globCache.LoadOrStore(pattern, globRegexp(pattern))
+	}
+	return re.(*regexp.Regexp).MatchString(p)
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// regexPrefix marks a path pattern as a regular expression.
+const regexPrefix = "re:"
+
+// MatchPath matches p against a path pattern from the rule switches:
+//
+//   - "re:<expr>" is a regular expression searched for in p;
+//   - a pattern holding "*" or "?" is a glob, see MatchGlob;
+//   - a literal holding "/" must start and end on a "/" boundary, so
+//     "generated/" matches "src/generated/x.go" but not "nongenerated/x.go";
+//   - any other literal is a substring of p, as it always was, so
+//     "generated.go" still matches "foo_generated.go".
+func MatchPath(pattern, p string) bool {
+	switch {
+	case strings.HasPrefix(pattern, regexPrefix):
+		re, err := pathRegexp(pattern)
+		return err == nil && re.MatchString(p)
+	case strings.ContainsAny(pattern, "*?"):
+		return MatchGlob(pattern, p)
+	case !strings.Contains(pattern, "/"):
+		return strings.Contains(p, pattern)
+	}
+	lit := pattern
+	if !strings.HasPrefix(lit, "/") {
+		lit = "/" + lit
+	}
+	if !strings.HasSuffix(lit, "/") {
+		lit += "/"
+	}
+	return strings.Contains("/"+strings.Trim(p, "/")+"/", lit)
+}
+
+func pathRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := pathReCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(strings.TrimPrefix(pattern, regexPrefix))
+	if err != nil {
+		return nil, err
+	}
+	pathReCache.Store(pattern, re)
+	return re, nil
+}
+
+// specificity ranks path patterns by their literal characters; wildcards and
+// regex syntax count for nothing.
+func specificity(pattern string) int {
+	if strings.HasPrefix(pattern, regexPrefix) {
+		n := 0
+		for _, c := range strings.TrimPrefix(pattern, regexPrefix) {
+			if !strings.ContainsRune(`\.+*?()|[]{}^$`, c) {
+				n++
+			}
+		}
+		return n
+	}
+	return len(strings.Trim(pattern, "/")) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+}
+
+// MostSpecific returns the value of the most specific pattern in m matching p.
+// Ties go to the lexically smallest pattern so the result never depends on map
+// iteration order.
+func MostSpecific(m map[string]int, p string) (int, bool) {
+	best, bestScore, found := "", -1, false
+	for pat := range m {
+		if !MatchPath(pat, p) {
+			continue
+		}
+		s := specificity(pat)
+		if !found || s > bestScore || (s == bestScore && pat < best) {
+			best, bestScore, found = pat, s, true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	return m[best], true
+}
@@ -0,0 +1,88 @@
+package config
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.c", "drivers/net/eth.c", true},
+		{"*Activity*", "app/src/main/java/MainActivity.java", true},
+		{"drivers/*.c", "drivers/net/eth.c", false},
+		{"drivers/**/*.c", "drivers/net/eth.c", true},
+		{"drivers/**/*.c", "drivers/eth.c", true},
+		{"**/generated/**", "a/generated/b/c.go", true},
+		{"src/?.go", "src/ab.go", false},
+		{"third_party/**", "src/third_party/x.c", false},
+	}
+	for _, c := range cases {
+		if got := MatchGlob(c.pattern, c.path); got != c.want {
+			t.Fatalf("MatchGlob(%q, %q) = %v", c.pattern, c.path, got)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"generated/", "src/generated/x.go", true},
+		{"generated/", "generated/x.go", true},
+		{"generated/", "src/nongenerated/x.go", false},
+		{"/third_party/", "external/third_party/zlib.c", true},
+		{"/third_party/", "my_third_party/zlib.c", false},
+		{"Generated.java", "app/src/Generated.java", true},
+		{"Generated.java", "app/src/NotGenerated.java", true},
+		// The README example: names without "/" match as substrings.
+		{"generated.go", "pkg/foo_generated.go", true},
+		{"Test.java", "app/src/FooTest.java", true},
+		{"Test.java", "app/src/Tests.kt", false},
+		{"app/src/", "app/src/main/A.java", true},
+		{"**/generated/**", "a/generated/b.go", true},
+		{"**/generated/**", "a/nongenerated/b.go", false},
+		{"*.pb.go", "api/v1/svc.pb.go", true},
+		{`re:_test\.go$`, "pkg/a_test.go", true},
+		{`re:^vendor/`, "src/vendor/x.go", false},
+		{`re:(`, "anything", false},
+	}
+	for _, c := range cases {
+		if got := MatchPath(c.pattern, c.path); got != c.want {
+			t.Fatalf("MatchPath(%q, %q) = %v", c.pattern, c.path, got)
+		}
+	}
+}
+
+func TestMostSpecific(t *testing.T) {
+	limits := map[string]int{
+		"app/":                 300,
+		"app/src/":             120,
+		"app/src/main/legacy/": 900,
+		"**/legacy/**":         500,
+		`re:^app/src/.*Test\.`: 2000,
+		"lib/":                 50,
+		"lib/*/":               60,
+	}
+	cases := []struct {
+		path string
+		want int
+		ok   bool
+	}{
+		{"app/build.gradle", 300, true},
+		{"app/src/main/A.java", 120, true},
+		{"app/src/main/legacy/Old.java", 900, true},
+		{"tools/legacy/x.py", 500, true},
+		{"app/src/main/FooTest.java", 2000, true},
+		{"docs/readme.md", 0, false},
+	}
+	for _, c := range cases {
+		// Run several times: a map-order dependency would show up as flakiness.
+		for i := 0; i < 20; i++ {
+			got, ok := MostSpecific(limits, c.path)
+			if got != c.want || ok != c.ok {
+				t.Fatalf("MostSpecific(%q) = %d, %v; want %d", c.path, got, ok, c.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+)
+
+// RepoConfigFiles are the names of the in-repo review config, in lookup order.
+var RepoConfigFiles = []string{".ai-review.json", ".ai-review.yaml", ".ai-review.yml"}
+
+// maxRepoPrompt bounds the prompt extras a repository may add.
+const maxRepoPrompt = 4000
+
+var repoKeys = map[string]bool{"switches": true, "rules": true, "disabledRules": true, "ignore": true, "prompt": true, "minSeverity": true}
+
+var severityRank = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+// SeverityAtLeast reports whether sev is at or above min. An empty min keeps
+// everything; unknown severities are kept so nothing is hidden by a typo.
+func SeverityAtLeast(sev, min string) bool {
+	m, ok := severityRank[min]
+	if !ok {
+		return true
+	}
+	s, ok := severityRank[strings.ToLower(sev)]
+	return !ok || s >= m
+}
+
+// Ignored reports whether path matches one of the profile's ignore globs.
+func (p RuleProfile) Ignored(path string) bool {
+	for _, g := range p.Ignore {
+		if MatchGlob(g, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyRepoConfig merges an in-repo review config named name over p. Switches
+// and rules merge as in a server-side profile; ignore globs, prompt extras and
+// the severity threshold come from the repository only. YAML is accepted for
+// .yaml and .yml names. A non-nil error is always a *RuleConfigError and p is
+// then returned unchanged.
+func ApplyRepoConfig(p RuleProfile, name string, b []byte) (RuleProfile, error) {
+	errs := &RuleConfigError{}
+	if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") {
+		j, err := gjson.LoadYaml(b)
+		if err == nil {
+			b, err = j.ToJson()
+		}
+		if err != nil {
+			errs.add("", "%v", err)
+			return p, errs
+		}
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(b, &top); err != nil {
+		errs.add("", "%s", jsonErrorMsg(b, err))
+		return p, errs
+	}
+	checkKeys(errs, "", top, repoKeys, "")
+	var head struct {
+		DisabledRules []string `json:"disabledRules"`
+		Ignore        []string `json:"ignore"`
+		Prompt        string   `json:"prompt"`
+		MinSeverity   string   `json:"minSeverity"`
+	}
+	if !decodeField(errs, "", b, &head) {
+		return p, errs
+	}
+	for i, g := range head.Ignore {
+		if strings.TrimSpace(g) == "" {
+			errs.add(fmt.Sprintf("ignore[%d]", i), "must not be empty")
+		}
+	}
+	if len(head.Prompt) > maxRepoPrompt {
+		errs.add("prompt", "longer than %d bytes", maxRepoPrompt)
+	}
+	if _, ok := severityRank[head.MinSeverity]; head.MinSeverity != "" && !ok {
+		errs.add("minSeverity", "must be high, medium or low, got %q", head.MinSeverity)
+	}
+
+	out := p
+	if raw, ok := top["switches"]; ok {
+		out.Switches = normalizeSwitches(parseSwitches(errs, "switches", raw, p.Switches))
+	}
+	var own []PatternRule
+	if raw, ok := top["rules"]; ok {
+		own = parsePatternRules(errs, "rules", raw)
+	}
+	if len(errs.Errors) > 0 {
+		return p, errs
+	}
+	out.Rules = mergePatternRules(p.Rules, own, head.DisabledRules)
+	out.DisabledRules = append(append([]string(nil), p.DisabledRules...), head.DisabledRules...)
+	out.Ignore = head.Ignore
+	out.PromptExtra = strings.TrimSpace(head.Prompt)
+	out.MinSeverity = head.MinSeverity
+	out.RepoConfig = name
+	return out, nil
+}
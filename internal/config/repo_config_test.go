@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApplyRepoConfig(t *testing.T) {
+	base := RuleProfile{
+		Name:     "kernel",
+		Switches: RuleSwitches{LinuxSpinSleep: true, FunctionLengthLimit: 200},
+		Rules:    []PatternRule{{ID: "no-printk", Title: "t"}, {ID: "no-goto", Title: "t"}},
+	}
+	body := `{
+	  "switches": {"FileTooLong": true},
+	  "rules": [{"id": "no-bug-on", "pattern": "BUG_ON\\(", "title": "avoid BUG_ON"}],
+	  "disabledRules": ["no-goto", "file-too-long"],
+	  "ignore": ["third_party/**", "*.pb.go"],
+	  "prompt": "  Focus on locking.  ",
+	  "minSeverity": "medium"
+	}`
+	p, err := ApplyRepoConfig(base, ".ai-review.json", []byte(body))
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if p.Name != "kernel" || p.RepoConfig != ".ai-review.json" || !p.Switches.LinuxSpinSleep || !p.Switches.FileTooLong {
+		t.Fatalf("switches should merge over the server profile: %+v", p)
+	}
+	if len(p.Rules) != 2 || p.Rules[0].ID != "no-printk" || p.Rules[1].ID != "no-bug-on" || len(p.DisabledRules) != 2 {
+		t.Fatalf("unexpected rules: %+v %v", p.Rules, p.DisabledRules)
+	}
+	if p.PromptExtra != "Focus on locking." || p.MinSeverity != "medium" {
+		t.Fatalf("unexpected extras: %q %q", p.PromptExtra, p.MinSeverity)
+	}
+	if !p.Ignored("third_party/zlib/inflate.c") || !p.Ignored("api/v1/svc.pb.go") || p.Ignored("kernel/lock.c") {
+		t.Fatalf("unexpected ignore matching")
+	}
+	if base.Switches.FileTooLong || len(base.Rules) != 2 {
+		t.Fatalf("base profile was modified: %+v", base)
+	}
+
+	yml := "minSeverity: high\nignore:\n  - docs/**\nswitches:\n  FunctionLengthLimit: 80\n"
+	p, err = ApplyRepoConfig(base, ".ai-review.yaml", []byte(yml))
+	if err != nil || p.MinSeverity != "high" || p.Switches.FunctionLengthLimit != 80 || !p.Ignored("docs/a.md") {
+		t.Fatalf("yaml: %+v %v", p, err)
+	}
+
+	_, err = ApplyRepoConfig(base, ".ai-review.json", []byte(`{"minSeverity": "urgent", "ignore": [""], "rules": [{"id": "x", "pattern": "(", "title": "t"}], "typo": 1}`))
+	var ce *RuleConfigError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected *RuleConfigError, got %v", err)
+	}
+	for _, want := range []string{"typo", "ignore[0]", "minSeverity", "rules[0].pattern"} {
+		if !strings.Contains(err.Error(), want+":") {
+			t.Fatalf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestSeverityAtLeast(t *testing.T) {
+	for _, c := range []struct {
+		sev, min string
+		want     bool
+	}{
+		{"low", "", true},
+		{"low", "medium", false},
+		{"High", "medium", true},
+		{"medium", "medium", true},
+		{"weird", "high", true},
+	} {
+		if got := SeverityAtLeast(c.sev, c.min); got != c.want {
+			t.Fatalf("SeverityAtLeast(%q, %q) = %v", c.sev, c.min, got)
+		}
+	}
+}
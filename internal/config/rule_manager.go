@@ -1,9 +1,12 @@
 package config
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"os"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,6 +16,7 @@ type RuleSwitches struct {
 	AndroidUiSleep           bool
 	AndroidWebView           bool
 	FileTooLong              bool
+	FunctionTooLong          bool
 	WhiteListFiles           []string
 	FunctionLengthLimit      int
 	WhiteListFunctions       []string
@@ -20,69 +24,191 @@ type RuleSwitches struct {
 	WhiteListFunctionsByLang map[string][]string
 	LengthLimitByLang        map[string]int
 	PathLengthLimit          map[string]int
+	// FunctionLengthLimitByLang and PathFunctionLengthLimit override
+	// FunctionLengthLimit for function-too-long the way LengthLimitByLang and
+	// PathLengthLimit do for file-too-long.
+	FunctionLengthLimitByLang map[string]int
+	PathFunctionLengthLimit   map[string]int
+}
+
+// PatternRule is a check defined in rules.json: a regex matched against added
+// lines, optionally only inside a brace block whose header matches Within.
+type PatternRule struct {
+	ID        string   `json:"id"`
+	Languages []string `json:"languages"`
+	// Include and Exclude are path globs; an empty Include matches every path.
+	Include  []string `json:"include"`
+	Exclude  []string `json:"exclude"`
+	Pattern  string   `json:"pattern"`
+	Within   string   `json:"within"`
+	Severity string   `json:"severity"`
+	Title    string   `json:"title"`
+	Detail   string   `json:"detail"`
+	Suggest  string   `json:"suggest"`
+
+	patternRe *regexp.Regexp
+	withinRe  *regexp.Regexp
+}
+
+// PatternRe returns the compiled Pattern.
+func (r PatternRule) PatternRe() *regexp.Regexp { return r.patternRe }
+
+// WithinRe returns the compiled Within, or nil when the rule is not scoped.
+func (r PatternRule) WithinRe() *regexp.Regexp { return r.withinRe }
+
+// DefaultProfile names the top-level switches and rules, used for projects no
+// profile matches.
+const DefaultProfile = "default"
+
+// RuleProfile is the rule config for a set of Gerrit projects and, optionally,
+// branches. Switches and Rules are already merged over the top-level ones.
+type RuleProfile struct {
+	Name string `json:"name"`
+	// Projects and Branches are globs matched against the whole project name
+	// and the branch without "refs/heads/"; empty Branches matches every branch.
+	Projects      []string      `json:"projects"`
+	Branches      []string      `json:"branches,omitempty"`
+	Switches      RuleSwitches  `json:"switches"`
+	Rules         []PatternRule `json:"rules"`
+	DisabledRules []string      `json:"disabledRules,omitempty"`
+
+	// Set by ApplyRepoConfig from the repository's own review config.
+	Ignore      []string `json:"ignore,omitempty"`
+	PromptExtra string   `json:"prompt,omitempty"`
+	MinSeverity string   `json:"minSeverity,omitempty"`
+	RepoConfig  string   `json:"repoConfig,omitempty"`
+}
+
+func (p RuleProfile) matches(project, branch string) bool {
+	ok := false
+	for _, g := range p.Projects {
+		if MatchFullGlob(g, project) {
+			ok = true
+			break
+		}
+	}
+	if !ok || len(p.Branches) == 0 {
+		return ok
+	}
+	for _, g := range p.Branches {
+		if MatchFullGlob(g, branch) {
+			return true
+		}
+	}
+	return false
 }
 
 var (
-	ruleCfg     RuleSwitches
-	mu          sync.RWMutex
-	lastModTime time.Time
+	ruleCfg      RuleSwitches
+	patternRules []PatternRule
+	profiles     []RuleProfile
+	ruleStatus   RuleConfigStatus
+	mu           sync.RWMutex
+	lastModTime  time.Time
 )
 
-func LoadRuleConfig(path string) {
+// RuleConfigStatus describes the active rule config and the last load attempt.
+type RuleConfigStatus struct {
+	Path     string        `json:"path"`
+	Hash     string        `json:"hash"`
+	Version  int           `json:"version"`
+	LoadedAt time.Time     `json:"loadedAt"`
+	Switches RuleSwitches  `json:"switches"`
+	Rules    []PatternRule `json:"rules"`
+	Profiles []RuleProfile `json:"profiles,omitempty"`
+	// LastError is set when the newest file on disk failed to load and the
+	// config above is still the previous one.
+	LastError   *RuleConfigError `json:"lastError,omitempty"`
+	LastErrorAt time.Time        `json:"lastErrorAt,omitempty"`
+}
+
+// LoadRuleConfig loads path if it changed since the last successful load. On
+// failure the active config is kept and the error is returned and recorded.
+func LoadRuleConfig(path string) error {
 	fi, err := os.Stat(path)
 	if err != nil {
-		return
+		return recordRuleError(path, &RuleConfigError{Errors: []FieldError{{Msg: err.Error()}}})
 	}
-	if fi.ModTime() == lastModTime {
-		return
+	mu.RLock()
+	unchanged := fi.ModTime() == lastModTime && ruleStatus.Path == path
+	mu.RUnlock()
+	if unchanged {
+		return nil
 	}
 	b, err := os.ReadFile(path)
 	if err != nil {
-		log.Printf("failed to read rule config: %v", err)
-		return
-	}
-	var raw map[string]any
-	if err := json.Unmarshal(b, &raw); err != nil {
-		log.Printf("failed to unmarshal rule config: %v", err)
-		return
-	}
-	allow := map[string]struct{}{
-		"LinuxSpinSleep":           {},
-		"AndroidUiSleep":           {},
-		"AndroidWebView":           {},
-		"FileTooLong":              {},
-		"WhiteListFiles":           {},
-		"FunctionLengthLimit":      {},
-		"WhiteListFunctions":       {},
-		"WhiteListFilesByLang":     {},
-		"WhiteListFunctionsByLang": {},
-		"LengthLimitByLang":        {},
-		"PathLengthLimit":          {},
+		return recordRuleError(path, &RuleConfigError{Errors: []FieldError{{Msg: err.Error()}}})
 	}
-	for k := range raw {
-		if _, ok := allow[k]; !ok {
-			return
-		}
-	}
-	var tmp RuleSwitches
-	if json.Unmarshal(b, &tmp) != nil {
-		return
-	}
-	if tmp.FunctionLengthLimit == 0 {
-		tmp.FunctionLengthLimit = 200
+	doc, err := parseRuleConfig(b)
+	if err != nil {
+		return recordRuleError(path, err.(*RuleConfigError))
 	}
-	tmp.WhiteListFiles = dedup(tmp.WhiteListFiles)
-	tmp.WhiteListFunctions = dedup(tmp.WhiteListFunctions)
+	sum := sha256.Sum256(b)
 	mu.Lock()
-	ruleCfg = tmp
+	ruleCfg = doc.Switches
+	patternRules = doc.Rules
+	profiles = doc.Profiles
 	lastModTime = fi.ModTime()
+	ruleStatus = RuleConfigStatus{Path: path, Hash: hex.EncodeToString(sum[:]), Version: doc.Version, LoadedAt: time.Now(), Switches: doc.Switches, Rules: doc.Rules, Profiles: doc.Profiles}
+	mu.Unlock()
+	return nil
+}
+
+func recordRuleError(path string, e *RuleConfigError) error {
+	mu.Lock()
+	// Only log a given failure once; the loader polls every 30 seconds.
+	repeated := ruleStatus.LastError != nil && ruleStatus.LastError.Error() == e.Error()
+	ruleStatus.LastError = e
+	ruleStatus.LastErrorAt = time.Now()
+	if ruleStatus.Path == "" {
+		ruleStatus.Path = path
+	}
 	mu.Unlock()
+	if !repeated {
+		log.Printf("failed to load rule config %s: %v", path, e)
+	}
+	return e
+}
+
+// RuleConfigInfo returns the active rule config and its load status.
+func RuleConfigInfo() RuleConfigStatus {
+	mu.RLock()
+	defer mu.RUnlock()
+	st := ruleStatus
+	st.Switches = ruleCfg
+	st.Rules = patternRules
+	st.Profiles = profiles
+	return st
 }
 
 func GetRuleSwitches() RuleSwitches { mu.RLock(); defer mu.RUnlock(); return ruleCfg }
 
 func SetRuleSwitches(rs RuleSwitches) { mu.Lock(); ruleCfg = rs; mu.Unlock() }
 
+// GetPatternRules returns the declarative rules from the last successful load.
+func GetPatternRules() []PatternRule { mu.RLock(); defer mu.RUnlock(); return patternRules }
+
+func SetPatternRules(rs []PatternRule) { mu.Lock(); patternRules = rs; mu.Unlock() }
+
+// SetRuleProfiles replaces the profiles from the last successful load.
+func SetRuleProfiles(ps []RuleProfile) { mu.Lock(); profiles = ps; mu.Unlock() }
+
+// RuleProfileFor returns the first profile matching project and branch, or the
+// default profile built from the top-level switches and rules.
+func RuleProfileFor(project, branch string) RuleProfile {
+	branch = strings.TrimPrefix(branch, "refs/heads/")
+	mu.RLock()
+	defer mu.RUnlock()
+	if project != "" {
+		for _, p := range profiles {
+			if p.matches(project, branch) {
+				return p
+			}
+		}
+	}
+	return RuleProfile{Name: DefaultProfile, Switches: ruleCfg, Rules: patternRules}
+}
+
 func dedup(arr []string) []string {
 	if len(arr) == 0 {
 		return arr
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRuleConfigVersions(t *testing.T) {
+	b, err := os.ReadFile("examples/rules.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, prs, err := ParseRuleConfig(b)
+	if err != nil || !rs.LinuxSpinSleep || len(prs) != 0 {
+		t.Fatalf("v1 example: %+v %v", rs, err)
+	}
+	if _, _, err := ParseRuleConfig([]byte(`{"LinuxSpinSleep": true, "Rules": []}`)); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Fatalf("v1 with unknown key should point at the version field, got %v", err)
+	}
+
+	v2 := `{
+	  "version": 2,
+	  "switches": {"FileTooLong": true},
+	  "rules": [{"id": "no-printk", "languages": ["c"], "include": ["drivers/**"], "pattern": "\\bprintk\\(", "title": "use dev_*"}]
+	}`
+	rs, prs, err = ParseRuleConfig([]byte(v2))
+	if err != nil {
+		t.Fatalf("v2: %v", err)
+	}
+	if !rs.FileTooLong || rs.FunctionLengthLimit != 200 || len(prs) != 1 || prs[0].Severity != "medium" || prs[0].PatternRe() == nil {
+		t.Fatalf("unexpected v2 result: %+v %+v", rs, prs)
+	}
+
+	for name, body := range map[string]string{
+		"future version": `{"version": 9}`,
+		"unknown field":  `{"version": 2, "rules": [{"id": "x", "pattern": "a", "title": "t", "regex": "b"}]}`,
+		"bad regex":      `{"version": 2, "rules": [{"id": "x", "pattern": "(", "title": "t"}]}`,
+		"bad within":     `{"version": 2, "rules": [{"id": "x", "pattern": "a", "within": "[", "title": "t"}]}`,
+		"duplicate id":   `{"version": 2, "rules": [{"id": "x", "pattern": "a", "title": "t"}, {"id": "x", "pattern": "b", "title": "t"}]}`,
+		"bad severity":   `{"version": 2, "rules": [{"id": "x", "pattern": "a", "title": "t", "severity": "fatal"}]}`,
+	} {
+		if _, _, err := ParseRuleConfig([]byte(body)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRuleConfigErrorPaths(t *testing.T) {
+	body := `{
+	  "version": 2,
+	  "switches": {"LinuxSpinSleep": "yes", "Typo": true},
+	  "rules": [
+	    {"id": "a", "pattern": "(", "title": "t", "severity": "fatal"},
+	    {"id": "a", "pattern": "x", "title": "t", "regex": "y"}
+	  ]
+	}`
+	_, _, err := ParseRuleConfig([]byte(body))
+	var ce *RuleConfigError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected *RuleConfigError, got %v", err)
+	}
+	var paths []string
+	for _, fe := range ce.Errors {
+		paths = append(paths, fe.Path)
+	}
+	want := []string{"switches.Typo", "switches.LinuxSpinSleep", "rules[0].pattern", "rules[0].severity", "rules[1].regex", "rules[1].id"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+
+	_, _, err = ParseRuleConfig([]byte("{\n  \"LinuxSpinSleep\": true,\n}"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("syntax error should carry a position, got %v", err)
+	}
+}
+
+func TestLoadRuleConfigKeepsActiveConfigOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2, "switches": {"AndroidWebView": true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadRuleConfig(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	st := RuleConfigInfo()
+	if st.Path != path || st.Version != 2 || len(st.Hash) != 64 || st.LoadedAt.IsZero() || !st.Switches.AndroidWebView || st.LastError != nil {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 2, "switches": {"AndroidWebView": 1}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification time moves even on coarse filesystems.
+	_ = os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if err := LoadRuleConfig(path); err == nil {
+		t.Fatalf("expected load error")
+	}
+	st2 := RuleConfigInfo()
+	if st2.Hash != st.Hash || !GetRuleSwitches().AndroidWebView || st2.LastError == nil || st2.LastError.Errors[0].Path != "switches.AndroidWebView" {
+		t.Fatalf("broken file must not replace the active config: %+v", st2)
+	}
+	if err := LoadRuleConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestRuleProfiles(t *testing.T) {
+	body := `{
+	  "version": 3,
+	  "switches": {"LinuxSpinSleep": true, "LengthLimitByLang": {"c": 800}},
+	  "rules": [
+	    {"id": "no-printk", "pattern": "printk\\(", "title": "use dev_*"},
+	    {"id": "no-goto", "pattern": "\\bgoto\\b", "title": "avoid goto"}
+	  ],
+	  "profiles": [
+	    {"name": "kernel-stable", "projects": ["kernel/*"], "branches": ["stable/**"],
+	     "switches": {"FileTooLong": true, "LengthLimitByLang": {"java": 500}},
+	     "disabledRules": ["no-goto"]},
+	    {"name": "kernel", "projects": ["kernel/*"],
+	     "rules": [{"id": "no-printk", "pattern": "pr_info\\(", "title": "quiet"}]}
+	  ]
+	}`
+	st, err := ValidateRuleConfig([]byte(body))
+	if err != nil {
+		t.Fatalf("v3: %v", err)
+	}
+	SetRuleSwitches(st.Switches)
+	SetPatternRules(st.Rules)
+	SetRuleProfiles(st.Profiles)
+	defer func() { SetRuleSwitches(RuleSwitches{}); SetPatternRules(nil); SetRuleProfiles(nil) }()
+
+	p := RuleProfileFor("kernel/common", "refs/heads/stable/5.10")
+	if p.Name != "kernel-stable" || !p.Switches.LinuxSpinSleep || !p.Switches.FileTooLong {
+		t.Fatalf("stable profile should inherit and extend the top-level switches: %+v", p)
+	}
+	if p.Switches.LengthLimitByLang["c"] != 800 || p.Switches.LengthLimitByLang["java"] != 500 {
+		t.Fatalf("maps should merge key by key: %v", p.Switches.LengthLimitByLang)
+	}
+	if len(p.Rules) != 1 || p.Rules[0].ID != "no-printk" {
+		t.Fatalf("disabled rule should be dropped: %+v", p.Rules)
+	}
+	if _, ok := GetRuleSwitches().LengthLimitByLang["java"]; ok {
+		t.Fatalf("profile switches leaked into the default")
+	}
+
+	p = RuleProfileFor("kernel/common", "main")
+	if p.Name != "kernel" || p.Switches.FileTooLong || len(p.Rules) != 2 || p.Rules[1].Pattern != `pr_info\(` {
+		t.Fatalf("kernel profile should override no-printk by id: %+v", p)
+	}
+	for _, project := range []string{"vendor/kernel/common", "android", ""} {
+		if p := RuleProfileFor(project, "main"); p.Name != DefaultProfile || len(p.Rules) != 2 {
+			t.Fatalf("%q should use the default profile, got %+v", project, p)
+		}
+	}
+
+	for name, body := range map[string]string{
+		"profiles in v2":   `{"version": 2, "profiles": []}`,
+		"reserved name":    `{"version": 3, "profiles": [{"name": "default", "projects": ["a"]}]}`,
+		"duplicate name":   `{"version": 3, "profiles": [{"name": "a", "projects": ["a"]}, {"name": "a", "projects": ["b"]}]}`,
+		"no projects":      `{"version": 3, "profiles": [{"name": "a"}]}`,
+		"bad profile rule": `{"version": 3, "profiles": [{"name": "a", "projects": ["a"], "rules": [{"id": "x", "pattern": "(", "title": "t"}]}]}`,
+	} {
+		if _, err := ValidateRuleConfig([]byte(body)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	_, err = ValidateRuleConfig([]byte(`{"version": 3, "profiles": [{"name": "a", "projects": ["a"], "switches": {"Typo": 1}}]}`))
+	if err == nil || !strings.Contains(err.Error(), "profiles[0].switches.Typo") {
+		t.Fatalf("profile errors should carry their path, got %v", err)
+	}
+}
+
+func TestRuleConfigRejectsBadPathRegex(t *testing.T) {
+	_, _, err := ParseRuleConfig([]byte(`{"version": 2, "switches": {"WhiteListFiles": ["ok/", "re:("], "PathLengthLimit": {"re:[": 10}}}`))
+	if err == nil || !strings.Contains(err.Error(), "switches.WhiteListFiles[1]") || !strings.Contains(err.Error(), "switches.PathLengthLimit.re:[") {
+		t.Fatalf("bad path regexes should be reported by field, got %v", err)
+	}
+}
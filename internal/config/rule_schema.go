@@ -0,0 +1,414 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RuleConfigVersion is the newest rules.json schema this build reads. Files
+// without a "version" key are version 1: a flat RuleSwitches object.
+//
+// Version 2 adds declarative rules, version 3 adds per-project profiles:
+//
+//	{"version": 3, "switches": {...}, "rules": [...], "profiles": [...RuleProfile...]}
+const RuleConfigVersion = 3
+
+// FieldError is one problem in a rule config. Path points at the offending
+// field, e.g. "rules[2].pattern"; it is empty for whole-document errors.
+type FieldError struct {
+	Path string `json:"path"`
+	Msg  string `json:"msg"`
+}
+
+// RuleConfigError lists every problem found in a rule config.
+type RuleConfigError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *RuleConfigError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		if fe.Path == "" {
+			parts = append(parts, fe.Msg)
+		} else {
+			parts = append(parts, fe.Path+": "+fe.Msg)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *RuleConfigError) add(path, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+var (
+	switchKeys  = keySet(RuleSwitches{})
+	ruleKeys    = keySet(PatternRule{})
+	profileKeys = map[string]bool{"name": true, "projects": true, "branches": true, "switches": true, "rules": true, "disabledRules": true}
+	docKeys     = map[int]map[string]bool{
+		2: {"version": true, "switches": true, "rules": true},
+		3: {"version": true, "switches": true, "rules": true, "profiles": true},
+	}
+)
+
+// keySet lists the JSON names of v's exported fields.
+func keySet(v interface{}) map[string]bool {
+	b, _ := json.Marshal(v)
+	var m map[string]json.RawMessage
+	_ = json.Unmarshal(b, &m)
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}
+
+// ParseRuleConfig decodes and validates a rules.json document of any supported
+// version. A non-nil error is always a *RuleConfigError.
+func ParseRuleConfig(b []byte) (RuleSwitches, []PatternRule, error) {
+	doc, err := parseRuleConfig(b)
+	if err != nil {
+		return RuleSwitches{}, nil, err
+	}
+	return doc.Switches, doc.Rules, nil
+}
+
+// ValidateRuleConfig parses a rules.json document without activating it and
+// returns what loading it would produce.
+func ValidateRuleConfig(b []byte) (RuleConfigStatus, error) {
+	doc, err := parseRuleConfig(b)
+	if err != nil {
+		return RuleConfigStatus{}, err
+	}
+	return RuleConfigStatus{Version: doc.Version, Switches: doc.Switches, Rules: doc.Rules, Profiles: doc.Profiles}, nil
+}
+
+type ruleDoc struct {
+	Version  int
+	Switches RuleSwitches
+	Rules    []PatternRule
+	Profiles []RuleProfile
+}
+
+func parseRuleConfig(b []byte) (*ruleDoc, error) {
+	errs := &RuleConfigError{}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(b, &top); err != nil {
+		errs.add("", "%s", jsonErrorMsg(b, err))
+		return nil, errs
+	}
+
+	doc := &ruleDoc{Version: 1}
+	if raw, ok := top["version"]; !ok {
+		checkKeys(errs, "", top, switchKeys, fmt.Sprintf(`; pattern rules need "version": %d`, RuleConfigVersion))
+		if decodeField(errs, "", b, &doc.Switches) {
+			checkPathPatterns(errs, "", doc.Switches)
+		}
+	} else {
+		if err := json.Unmarshal(raw, &doc.Version); err != nil {
+			errs.add("version", "must be an integer")
+		} else if docKeys[doc.Version] == nil {
+			errs.add("version", "unsupported version %d (this build reads up to %d)", doc.Version, RuleConfigVersion)
+		}
+		if len(errs.Errors) > 0 {
+			return nil, errs
+		}
+		checkKeys(errs, "", top, docKeys[doc.Version], "")
+		if raw, ok := top["switches"]; ok {
+			doc.Switches = parseSwitches(errs, "switches", raw, RuleSwitches{})
+		}
+		if raw, ok := top["rules"]; ok {
+			doc.Rules = parsePatternRules(errs, "rules", raw)
+		}
+	}
+	if len(errs.Errors) > 0 {
+		return nil, errs
+	}
+	doc.Switches = normalizeSwitches(doc.Switches)
+	if raw, ok := top["profiles"]; ok {
+		doc.Profiles = parseProfiles(errs, raw, doc.Switches, doc.Rules)
+		if len(errs.Errors) > 0 {
+			return nil, errs
+		}
+	}
+	return doc, nil
+}
+
+// parseSwitches decodes a switches object over base: fields present in raw
+// replace those of base, maps are merged key by key and lists are replaced.
+func parseSwitches(errs *RuleConfigError, path string, raw json.RawMessage, base RuleSwitches) RuleSwitches {
+	var m map[string]json.RawMessage
+	if json.Unmarshal(raw, &m) != nil {
+		errs.add(path, "must be an object")
+		return base
+	}
+	checkKeys(errs, path, m, switchKeys, "")
+	rs := copySwitches(base)
+	if decodeField(errs, path, raw, &rs) {
+		checkPathPatterns(errs, path, rs)
+	}
+	return rs
+}
+
+// checkPathPatterns reports "re:" path patterns that do not compile.
+func checkPathPatterns(errs *RuleConfigError, prefix string, rs RuleSwitches) {
+	check := func(path, pattern string) {
+		if strings.HasPrefix(pattern, regexPrefix) {
+			if _, err := pathRegexp(pattern); err != nil {
+				errs.add(path, "%v", err)
+			}
+		}
+	}
+	for i, p := range rs.WhiteListFiles {
+		check(fmt.Sprintf("%s[%d]", joinPath(prefix, "WhiteListFiles"), i), p)
+	}
+	for _, lang := range sortedKeys(rs.WhiteListFilesByLang) {
+		for i, p := range rs.WhiteListFilesByLang[lang] {
+			check(fmt.Sprintf("%s.%s[%d]", joinPath(prefix, "WhiteListFilesByLang"), lang, i), p)
+		}
+	}
+	for _, p := range sortedKeys(rs.PathLengthLimit) {
+		check(joinPath(prefix, "PathLengthLimit")+"."+p, p)
+	}
+	for _, p := range sortedKeys(rs.PathFunctionLengthLimit) {
+		check(joinPath(prefix, "PathFunctionLengthLimit")+"."+p, p)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func copySwitches(rs RuleSwitches) RuleSwitches {
+	rs.WhiteListFiles = append([]string(nil), rs.WhiteListFiles...)
+	rs.WhiteListFunctions = append([]string(nil), rs.WhiteListFunctions...)
+	rs.WhiteListFilesByLang = copyMap(rs.WhiteListFilesByLang)
+	rs.WhiteListFunctionsByLang = copyMap(rs.WhiteListFunctionsByLang)
+	rs.LengthLimitByLang = copyMap(rs.LengthLimitByLang)
+	rs.PathLengthLimit = copyMap(rs.PathLengthLimit)
+	rs.FunctionLengthLimitByLang = copyMap(rs.FunctionLengthLimitByLang)
+	rs.PathFunctionLengthLimit = copyMap(rs.PathFunctionLengthLimit)
+	return rs
+}
+
+func copyMap[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]V, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func parseProfiles(errs *RuleConfigError, raw json.RawMessage, base RuleSwitches, baseRules []PatternRule) []RuleProfile {
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		errs.add("profiles", "must be an array")
+		return nil
+	}
+	seen := map[string]int{DefaultProfile: -1}
+	out := make([]RuleProfile, 0, len(items))
+	for i, item := range items {
+		p := fmt.Sprintf("profiles[%d]", i)
+		var m map[string]json.RawMessage
+		if json.Unmarshal(item, &m) != nil {
+			errs.add(p, "must be an object")
+			continue
+		}
+		checkKeys(errs, p, m, profileKeys, "")
+		var head struct {
+			Name          string   `json:"name"`
+			Projects      []string `json:"projects"`
+			Branches      []string `json:"branches"`
+			DisabledRules []string `json:"disabledRules"`
+		}
+		if !decodeField(errs, p, item, &head) {
+			continue
+		}
+		if head.Name == "" {
+			errs.add(p+".name", "is required")
+		} else if j, dup := seen[head.Name]; dup {
+			if j < 0 {
+				errs.add(p+".name", "%q is reserved for the top-level settings", head.Name)
+			} else {
+				errs.add(p+".name", "duplicate name %q, also used by profiles[%d]", head.Name, j)
+			}
+		} else {
+			seen[head.Name] = i
+		}
+		if len(head.Projects) == 0 {
+			errs.add(p+".projects", "at least one project pattern is required")
+		}
+		prof := RuleProfile{Name: head.Name, Projects: head.Projects, Branches: head.Branches, DisabledRules: head.DisabledRules, Switches: copySwitches(base)}
+		if raw, ok := m["switches"]; ok {
+			prof.Switches = normalizeSwitches(parseSwitches(errs, p+".switches", raw, base))
+		}
+		var own []PatternRule
+		if raw, ok := m["rules"]; ok {
+			own = parsePatternRules(errs, p+".rules", raw)
+		}
+		prof.Rules = mergePatternRules(baseRules, own, head.DisabledRules)
+		out = append(out, prof)
+	}
+	return out
+}
+
+// mergePatternRules overlays own on base by ID and drops disabled IDs.
+func mergePatternRules(base, own []PatternRule, disabled []string) []PatternRule {
+	drop := make(map[string]bool, len(disabled)+len(own))
+	for _, id := range disabled {
+		drop[id] = true
+	}
+	for _, r := range own {
+		drop[r.ID] = true
+	}
+	out := make([]PatternRule, 0, len(base)+len(own))
+	for _, r := range base {
+		if !drop[r.ID] {
+			out = append(out, r)
+		}
+	}
+	for _, r := range own {
+		if !contains(disabled, r.ID) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePatternRules(errs *RuleConfigError, path string, raw json.RawMessage) []PatternRule {
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		errs.add(path, "must be an array")
+		return nil
+	}
+	seen := make(map[string]int, len(items))
+	out := make([]PatternRule, 0, len(items))
+	for i, item := range items {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		var m map[string]json.RawMessage
+		if json.Unmarshal(item, &m) != nil {
+			errs.add(p, "must be an object")
+			continue
+		}
+		checkKeys(errs, p, m, ruleKeys, "")
+		var r PatternRule
+		if !decodeField(errs, p, item, &r) {
+			continue
+		}
+		if r.ID == "" {
+			errs.add(p+".id", "is required")
+		} else if j, dup := seen[r.ID]; dup {
+			errs.add(p+".id", "duplicate id %q, also used by %s[%d]", r.ID, path, j)
+		} else {
+			seen[r.ID] = i
+		}
+		if r.Title == "" {
+			errs.add(p+".title", "is required")
+		}
+		var err error
+		if r.Pattern == "" {
+			errs.add(p+".pattern", "is required")
+		} else if r.patternRe, err = regexp.Compile(r.Pattern); err != nil {
+			errs.add(p+".pattern", "%v", err)
+		}
+		if r.Within != "" {
+			if r.withinRe, err = regexp.Compile(r.Within); err != nil {
+				errs.add(p+".within", "%v", err)
+			}
+		}
+		switch r.Severity {
+		case "":
+			r.Severity = "medium"
+		case "high", "medium", "low":
+		default:
+			errs.add(p+".severity", "must be high, medium or low, got %q", r.Severity)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// checkKeys reports keys of m that are not in allowed, in sorted order.
+func checkKeys(errs *RuleConfigError, prefix string, m map[string]json.RawMessage, allowed map[string]bool, hint string) {
+	var unknown []string
+	for k := range m {
+		if !allowed[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		errs.add(joinPath(prefix, k), "unknown field%s", hint)
+	}
+}
+
+// decodeField unmarshals raw into v, reporting type mismatches at their field path.
+func decodeField(errs *RuleConfigError, prefix string, raw []byte, v interface{}) bool {
+	err := json.Unmarshal(raw, v)
+	if err == nil {
+		return true
+	}
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		errs.add(joinPath(prefix, te.Field), "expected %s, got %s", te.Type, te.Value)
+	} else {
+		errs.add(prefix, "%s", jsonErrorMsg(raw, err))
+	}
+	return false
+}
+
+func joinPath(prefix, field string) string {
+	switch {
+	case prefix == "":
+		return field
+	case field == "":
+		return prefix
+	}
+	return prefix + "." + field
+}
+
+// jsonErrorMsg adds the line and column to JSON syntax errors.
+func jsonErrorMsg(b []byte, err error) string {
+	var se *json.SyntaxError
+	if !errors.As(err, &se) {
+		return err.Error()
+	}
+	off := int(se.Offset)
+	if off > len(b) {
+		off = len(b)
+	}
+	line := bytes.Count(b[:off], []byte("\n")) + 1
+	col := off - bytes.LastIndexByte(b[:off], '\n')
+	return fmt.Sprintf("line %d, column %d: %v", line, col, err)
+}
+
+func normalizeSwitches(rs RuleSwitches) RuleSwitches {
+	if rs.FunctionLengthLimit == 0 {
+		rs.FunctionLengthLimit = 200
+	}
+	rs.WhiteListFiles = dedup(rs.WhiteListFiles)
+	rs.WhiteListFunctions = dedup(rs.WhiteListFunctions)
+	return rs
+}
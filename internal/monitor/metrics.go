@@ -12,6 +12,9 @@ var GraphExecCount uint64
 var ContextFuncCount uint64
 var ContextClassCount uint64
 var ContextDepCount uint64
+var ContextCallerCount uint64
+var ContextCompareCount uint64
+var ContextBlameCount uint64
 
 func IncError() { atomic.AddUint64(&NodeErrors, 1) }
 func IncCall()  { atomic.AddUint64(&NodeCalls, 1) }
@@ -22,3 +25,6 @@ func AddGraphExecMillis(ms uint64) { atomic.AddUint64(&GraphExecMillisSum, ms);
 func IncContextFunc() { atomic.AddUint64(&ContextFuncCount, 1) }
 func IncContextClass() { atomic.AddUint64(&ContextClassCount, 1) }
 func IncContextDep() { atomic.AddUint64(&ContextDepCount, 1) }
+func IncContextCompare() { atomic.AddUint64(&ContextCompareCount, 1) }
+func AddContextCallers(n int) { atomic.AddUint64(&ContextCallerCount, uint64(n)) }
+func AddContextBlame(n int) { atomic.AddUint64(&ContextBlameCount, uint64(n)) }
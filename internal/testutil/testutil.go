@@ -0,0 +1,61 @@
+// Package testutil holds fixtures shared by the tests of several packages.
+package testutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// WriteFiles writes files, keyed by slash-separated path, under root.
+func WriteFiles(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+	for rel, s := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Commit is one commit of a GitRemote fixture. Files are written over the
+// previous commit's tree.
+type Commit struct {
+	Message string
+	Files   map[string]string
+}
+
+// GitRemote creates the bare repository remote holding commits, one on top
+// of the other, with the last one pushed to ref, e.g. a Gerrit change ref.
+// The test is skipped when git is not installed.
+func GitRemote(t testing.TB, remote, ref string, commits ...Commit) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	work := filepath.Join(t.TempDir(), "work")
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Ann", "GIT_AUTHOR_EMAIL=ann@example.com",
+			"GIT_COMMITTER_NAME=Ann", "GIT_COMMITTER_EMAIL=ann@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	run("init", "-q", "--bare", remote)
+	for _, c := range commits {
+		WriteFiles(t, work, c.Files)
+		run("add", ".")
+		run("commit", "-q", "-m", c.Message)
+	}
+	run("push", "-q", remote, "HEAD:"+ref)
+}
@@ -1,23 +1,64 @@
 package web
 
 import (
-	"context"
+	"errors"
 	"eino-gerrit-review/internal/app/eino/core"
 	"eino-gerrit-review/internal/app/eino/flows"
 	"eino-gerrit-review/internal/app/scheduler"
 	"eino-gerrit-review/internal/app/tools"
-	"fmt"
 
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 )
 
 func GetChanges(r *ghttp.Request) {
+	q := tools.ChangeQuery{
+		Project: r.Get("project").String(),
+		Branch:  r.Get("branch").String(),
+		Owner:   r.Get("owner").String(),
+		Topic:   r.Get("topic").String(),
+		Age:     r.Get("age").String(),
+		Query:   r.Get("q").String(),
+	}
+	for _, v := range []string{q.Project, q.Branch, q.Owner, q.Topic, q.Age} {
+		if !validQueryValue(v) {
+			r.Response.WriteJson(g.Map{"code": 1, "msg": "invalid filter"})
+			return
+		}
+	}
+	if len(q.Query) > 512 {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "query too long"})
+		return
+	}
+	fc := core.NewFlowContext()
+	fc.Data["query"] = q
+	fc.Data["limit"] = r.Get("limit", 25).Int()
 	f := &flows.IngestFlow{}
-	res, _ := f.Execute(context.Background(), core.NewFlowContext())
+	res, err := f.Execute(r.Context(), fc)
+	if err != nil {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "list changes failed: " + err.Error()})
+		return
+	}
 	r.Response.WriteJson(g.Map{"code": 0, "data": res["changes"]})
 }
 
+// validQueryValue accepts the characters that appear in Gerrit project, branch and
+// account names while rejecting whitespace and quotes that would add search operators.
+func validQueryValue(s string) bool {
+	if len(s) > 256 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '/' || b == '@' || b == '+' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
 func TriggerScan(r *ghttp.Request) {
 	project := r.Get("project").String()
 	branch := r.Get("branch").String()
@@ -31,17 +72,19 @@ func TriggerScan(r *ghttp.Request) {
 	}
 	gt := &tools.GerritTool{}
 	changes, _ := gt.GetOpenChanges(project, branch, 10)
-	pool := scheduler.NewWorkerPool(8)
-	pool.Run(context.Background())
-	for _, c := range changes {
-		// Use _number field from Gerrit API response as the unique identifier
-		num := ""
-		if n, ok := c["_number"].(float64); ok {
-			num = fmt.Sprintf("%.0f", n)
-		}
-		if num != "" {
-			pool.Submit(scheduler.Task{ChangeNum: num, Patchset: "1", EnableContext: r.Get("enableContext").Bool()})
+	tasks := scheduler.PendingTasks(changes, r.Get("enableContext").Bool())
+	jobs := make([]string, 0, len(tasks))
+	duplicate, rejected := 0, 0
+	for _, t := range tasks {
+		j, err := workerPool.SubmitJob(t)
+		switch {
+		case err == nil:
+			jobs = append(jobs, j.ID)
+		case errors.Is(err, scheduler.ErrDuplicateTask) || errors.Is(err, scheduler.ErrStaleTask):
+			duplicate++
+		default:
+			rejected++
 		}
 	}
-	r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{"scanned": len(changes), "queued": len(changes)}})
+	r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{"scanned": len(changes), "queued": len(jobs), "skipped": len(changes) - len(tasks), "duplicate": duplicate, "rejected": rejected, "jobs": jobs}})
 }
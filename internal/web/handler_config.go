@@ -37,6 +37,58 @@ func ReloadRules(r *ghttp.Request) {
 		r.Response.WriteJson(g.Map{"code": 1, "msg": "invalid path"})
 		return
 	}
-	config.LoadRuleConfig(absPath)
-	r.Response.WriteJson(g.Map{"code": 0, "msg": "ok"})
+	if err := config.LoadRuleConfig(absPath); err != nil {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "rule config not loaded, previous config still active", "data": err})
+		return
+	}
+	r.Response.WriteJson(g.Map{"code": 0, "msg": "ok", "data": ruleConfigView(config.RuleConfigInfo())})
+}
+
+// maxRuleConfigBytes bounds the body of POST /config/rules/validate.
+const maxRuleConfigBytes = 1 << 20
+
+// ValidateRules dry-runs a rule config sent as the request body without activating it.
+func ValidateRules(r *ghttp.Request) {
+	body := r.GetBody()
+	if len(body) == 0 {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "empty body"})
+		return
+	}
+	if len(body) > maxRuleConfigBytes {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "config too large"})
+		return
+	}
+	st, err := config.ValidateRuleConfig(body)
+	if err != nil {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "invalid rule config", "data": err})
+		return
+	}
+	r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{"valid": true, "version": st.Version, "switches": st.Switches, "rules": st.Rules, "profiles": st.Profiles}})
+}
+
+// GetRuleConfig returns the active rule config, where it came from and the last load error.
+// With ?project= (and optionally &branch=) it returns the profile that change would use.
+func GetRuleConfig(r *ghttp.Request) {
+	if project := r.Get("project").String(); project != "" {
+		r.Response.WriteJson(g.Map{"code": 0, "data": config.RuleProfileFor(project, r.Get("branch").String())})
+		return
+	}
+	r.Response.WriteJson(g.Map{"code": 0, "data": ruleConfigView(config.RuleConfigInfo())})
+}
+
+func ruleConfigView(st config.RuleConfigStatus) g.Map {
+	m := g.Map{
+		"path":     st.Path,
+		"hash":     st.Hash,
+		"version":  st.Version,
+		"loadedAt": st.LoadedAt,
+		"switches": st.Switches,
+		"rules":    st.Rules,
+		"profiles": st.Profiles,
+	}
+	if st.LastError != nil {
+		m["lastError"] = st.LastError
+		m["lastErrorAt"] = st.LastErrorAt
+	}
+	return m
 }
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"crypto/subtle"
+	"eino-gerrit-review/internal/app/scheduler"
+	"net/http"
+	"os"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// GerritEvents accepts Gerrit stream-events JSON (one event or newline-delimited)
+// pushed by the webhooks plugin or a stream-events forwarder.
+func GerritEvents(r *ghttp.Request) {
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		r.Response.WriteHeader(http.StatusForbidden)
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "webhook disabled"})
+		return
+	}
+	got := r.Header.Get("X-Gerrit-Secret")
+	if got == "" {
+		got = r.GetQuery("secret").String()
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+		r.Response.WriteHeader(http.StatusUnauthorized)
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "invalid secret"})
+		return
+	}
+	events, err := scheduler.ParseEventBytes(r.GetBody())
+	if err != nil {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "invalid event payload: " + err.Error()})
+		return
+	}
+	opts := scheduler.EventOptions{
+		EnableContext: os.Getenv("CONTEXT_ENABLED") != "false",
+		TriggerPhrase: getenvDefault("REVIEW_TRIGGER_PHRASE", "/ai-review"),
+		BotUser:       os.Getenv("GERRIT_USER"),
+	}
+	res := scheduler.DispatchEvents(events, opts, workerPool)
+	r.Response.WriteJson(g.Map{"code": 0, "data": res})
+}
+
+func getenvDefault(k, d string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return d
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"eino-gerrit-review/internal/app/scheduler"
+	"errors"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+func ListJobs(r *ghttp.Request) {
+	f := scheduler.JobFilter{
+		State:     scheduler.JobState(r.Get("state").String()),
+		ChangeNum: r.Get("changeNum").String(),
+		Limit:     r.Get("limit", 100).Int(),
+	}
+	if !validParam(string(f.State)) || !validParam(f.ChangeNum) {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "invalid param format"})
+		return
+	}
+	r.Response.WriteJson(g.Map{"code": 0, "data": workerPool.ListJobs(f)})
+}
+
+func GetJob(r *ghttp.Request) {
+	j, ok := workerPool.GetJob(r.Get("id").String())
+	if !ok {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "not found"})
+		return
+	}
+	r.Response.WriteJson(g.Map{"code": 0, "data": j})
+}
+
+func CancelJob(r *ghttp.Request) {
+	j, err := workerPool.CancelJob(r.Get("id").String())
+	switch {
+	case errors.Is(err, scheduler.ErrJobNotFound):
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "not found"})
+	case err != nil:
+		r.Response.WriteJson(g.Map{"code": 1, "msg": err.Error(), "data": j})
+	default:
+		r.Response.WriteJson(g.Map{"code": 0, "data": j})
+	}
+}
+
+func ListDeadLetters(r *ghttp.Request) {
+	r.Response.WriteJson(g.Map{"code": 0, "data": workerPool.DeadLetters()})
+}
+
+func RequeueDeadLetter(r *ghttp.Request) {
+	j, err := workerPool.Requeue(r.Get("id").String())
+	switch {
+	case errors.Is(err, scheduler.ErrJobNotFound):
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "not found"})
+	case err != nil:
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "requeue failed: " + err.Error()})
+	default:
+		r.Response.WriteJson(g.Map{"code": 0, "data": j})
+	}
+}
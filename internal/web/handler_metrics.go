@@ -3,6 +3,7 @@ package web
 import (
     "github.com/gogf/gf/v2/frame/g"
     "github.com/gogf/gf/v2/net/ghttp"
+    "eino-gerrit-review/internal/app/tools"
     "eino-gerrit-review/internal/monitor"
 )
 
@@ -18,5 +19,15 @@ func Metrics(r *ghttp.Request) {
         "context_func_count": monitor.ContextFuncCount,
         "context_class_count": monitor.ContextClassCount,
         "context_dep_count": monitor.ContextDepCount,
+        "context_caller_count": monitor.ContextCallerCount,
+        "context_compare_count": monitor.ContextCompareCount,
+        "context_blame_count": monitor.ContextBlameCount,
+        "caches": g.Map{"context": tools.ContextCacheStats()},
     }})
 }
+
+// FlushContextCache drops cached context files, only those of ?project= when given.
+func FlushContextCache(r *ghttp.Request) {
+    n := tools.FlushContextCache(r.Get("project").String())
+    r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{"flushed": n}})
+}
@@ -74,14 +74,22 @@ func RunReview(r *ghttp.Request) {
 	}
 	id := "R" + toStr(time.Now().UnixNano())
 	if v, ok := res["preview"].(map[string]interface{}); ok {
-		core.PutReview(id, v, req.ChangeNum, req.Patchset)
+		model, _ := res["model"].(string)
+		if model == "" {
+			model = tools.ModelName()
+		}
+		if err := core.PutReview(core.ReviewStored{ID: id, Payload: v, ChangeNum: req.ChangeNum, Patchset: req.Patchset, Model: model}); err != nil {
+			r.Response.WriteJson(g.Map{"code": 1, "msg": "store review failed: " + err.Error()})
+			return
+		}
 
 		// Check for AutoPublish
 		if req.AutoPublish {
 			gt := &tools.GerritTool{}
-			if _, err := gt.PostReview(req.ChangeNum, req.Patchset, v); err != nil {
+			_, err := gt.PostReview(req.ChangeNum, req.Patchset, v)
+			_ = core.MarkReviewPublished(id, err)
+			if err != nil {
 				// If publish fails, we still return the reviewId but with a warning or error msg
-				// For now, let's just log it or include in response
 				r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{"reviewId": id, "preview": res["preview"], "published": false, "publishError": err.Error()}})
 				return
 			}
@@ -120,12 +128,30 @@ func validParam(s string) bool {
 func GetReview(r *ghttp.Request) {
 	id := r.Get("id").String()
 	if v, ok := core.GetReview(id); ok {
-		r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{"reviewId": id, "preview": v.Payload, "changeNum": v.ChangeNum, "patchset": v.Patchset}})
+		r.Response.WriteJson(g.Map{"code": 0, "data": reviewView(v)})
 		return
 	}
 	r.Response.WriteJson(g.Map{"code": 1, "msg": "not found"})
 }
 
+func ListReviews(r *ghttp.Request) {
+	f := core.ReviewFilter{
+		ChangeNum: r.Get("changeNum").String(),
+		Patchset:  r.Get("patchset").String(),
+		Limit:     r.Get("limit", 50).Int(),
+	}
+	if !validParam(f.ChangeNum) || !validParam(f.Patchset) {
+		r.Response.WriteJson(g.Map{"code": 1, "msg": "invalid param format"})
+		return
+	}
+	list := core.ListReviews(f)
+	out := make([]g.Map, 0, len(list))
+	for _, v := range list {
+		out = append(out, reviewView(v))
+	}
+	r.Response.WriteJson(g.Map{"code": 0, "data": out})
+}
+
 func PublishReview(r *ghttp.Request) {
 	id := r.Get("id").String()
 	v, ok := core.GetReview(id)
@@ -134,9 +160,25 @@ func PublishReview(r *ghttp.Request) {
 		return
 	}
 	gt := &tools.GerritTool{}
-	if _, err := gt.PostReview(v.ChangeNum, v.Patchset, v.Payload); err != nil {
+	_, err := gt.PostReview(v.ChangeNum, v.Patchset, v.Payload)
+	_ = core.MarkReviewPublished(id, err)
+	if err != nil {
 		r.Response.WriteJson(g.Map{"code": 1, "msg": "post review failed: " + err.Error()})
 		return
 	}
 	r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{"published": true}})
 }
+
+func reviewView(v core.ReviewStored) g.Map {
+	return g.Map{
+		"reviewId":     v.ID,
+		"preview":      v.Payload,
+		"changeNum":    v.ChangeNum,
+		"patchset":     v.Patchset,
+		"model":        v.Model,
+		"published":    v.Published,
+		"publishError": v.PublishError,
+		"createdAt":    v.CreatedAt,
+		"publishedAt":  v.PublishedAt,
+	}
+}
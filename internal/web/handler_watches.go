@@ -0,0 +1,25 @@
+package web
+
+import (
+	"eino-gerrit-review/internal/app/scheduler"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// watchManager is nil when WATCH_CONFIG_PATH is not set.
+var watchManager *scheduler.WatchManager
+
+func SetWatchManager(m *scheduler.WatchManager) { watchManager = m }
+
+func ListWatches(r *ghttp.Request) {
+	if watchManager == nil {
+		r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{"watches": []scheduler.WatchStatus{}}})
+		return
+	}
+	r.Response.WriteJson(g.Map{"code": 0, "data": g.Map{
+		"path":      watchManager.Path(),
+		"loadError": watchManager.LoadError(),
+		"watches":   watchManager.Watches(),
+	}})
+}
@@ -1,16 +1,33 @@
 package web
 
 import (
+    "eino-gerrit-review/internal/app/scheduler"
+
     "github.com/gogf/gf/v2/net/ghttp"
 )
 
-func RegisterRoutes(s *ghttp.Server) {
+// workerPool is the long-lived pool shared by all handlers that queue reviews.
+var workerPool *scheduler.WorkerPool
+
+func RegisterRoutes(s *ghttp.Server, pool *scheduler.WorkerPool) {
+    workerPool = pool
     group := s.Group("/")
     group.GET("/changes", GetChanges)
     group.POST("/reviews/run", RunReview)
+    group.GET("/reviews", ListReviews)
     group.GET("/reviews/{id}", GetReview)
     group.POST("/reviews/{id}/publish", PublishReview)
     group.POST("/scheduler/scan", TriggerScan)
+    group.GET("/scheduler/watches", ListWatches)
+    group.POST("/events/gerrit", GerritEvents)
+    group.GET("/jobs", ListJobs)
+    group.GET("/jobs/{id}", GetJob)
+    group.POST("/jobs/{id}/cancel", CancelJob)
+    group.GET("/deadletters", ListDeadLetters)
+    group.POST("/deadletters/{id}/requeue", RequeueDeadLetter)
     group.GET("/metrics", Metrics)
+    group.POST("/cache/context/flush", FlushContextCache)
+    group.GET("/config/rules", GetRuleConfig)
     group.POST("/config/rules/reload", ReloadRules)
+    group.POST("/config/rules/validate", ValidateRules)
 }